@@ -0,0 +1,87 @@
+// Package apperr defines the errors the API returns to clients. Every error
+// response has the same shape:
+//
+//	{
+//	  "status": "error",
+//	  "code": "ORG_NOT_FOUND",
+//	  "message": "Organisation not found",
+//	  "statusCode": 404,
+//	  "errors": [{"field": "email", "message": "..."}]
+//	}
+//
+// code is one of the Code constants and is stable, so clients should switch
+// on it rather than on message. errors is only present when individual
+// request fields were rejected.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FieldError describes why one request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error that can be shown to the client as is. The wrapped
+// cause, if any, is only logged.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Fields  []FieldError
+	cause   error
+}
+
+// New returns the error for code with its catalogue status and message.
+func New(code Code) *Error {
+	entry, ok := catalogue[code]
+	if !ok {
+		panic(fmt.Sprintf("apperr: code %s is not in the catalogue", code))
+	}
+	return &Error{Status: entry.Status, Code: code, Message: entry.Message}
+}
+
+// Invalid reports request fields that failed validation.
+func Invalid(fields ...FieldError) *Error {
+	return New(ValidationFailed).WithFields(fields...)
+}
+
+// Internal hides cause from the client behind message, which should say
+// what could not be done.
+func Internal(cause error, message string) *Error {
+	e := New(InternalError)
+	e.Message = message
+	e.cause = cause
+	return e
+}
+
+// WithFields attaches field errors to e.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// From returns err as an *Error, treating anything unexpected as an
+// internal error.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err, http.StatusText(http.StatusInternalServerError))
+}
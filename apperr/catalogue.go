@@ -0,0 +1,176 @@
+package apperr
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Code identifies an error for clients. Codes are part of the API: once
+// published they keep their meaning.
+type Code string
+
+// The catalogue of error codes. The status and default message of each are
+// listed in catalogue below.
+const (
+	// ValidationFailed means the request body was rejected; the errors
+	// list says which fields and why.
+	ValidationFailed Code = "VALIDATION_FAILED"
+	// MalformedRequest means the request body could not be parsed as
+	// JSON at all.
+	MalformedRequest Code = "MALFORMED_REQUEST"
+	// NotFound means no route matches the request.
+	NotFound Code = "NOT_FOUND"
+	// RateLimited means the client sent too many requests; see the
+	// Retry-After header.
+	RateLimited Code = "RATE_LIMITED"
+	// Forbidden means the caller is authenticated but not allowed to do
+	// this.
+	Forbidden Code = "FORBIDDEN"
+	// InternalError means the server failed; retrying may help.
+	InternalError Code = "INTERNAL_ERROR"
+	// ServerBusy means the server is overloaded and turned the request
+	// away; retry after the Retry-After header.
+	ServerBusy Code = "SERVER_BUSY"
+
+	// AuthMissingToken means the request needs an access token and had
+	// none.
+	AuthMissingToken Code = "AUTH_MISSING_TOKEN"
+	// AuthInvalidToken means the access token is malformed, expired or
+	// not signed by us.
+	AuthInvalidToken Code = "AUTH_INVALID_TOKEN"
+	// AuthTokenRevoked means the access token was revoked by a logout.
+	AuthTokenRevoked Code = "AUTH_TOKEN_REVOKED"
+	// AuthInvalidCredentials means the email, password or second factor
+	// is wrong. Which one is deliberately not revealed.
+	AuthInvalidCredentials Code = "AUTH_INVALID_CREDENTIALS"
+	// AuthEmailNotVerified means the account's address must be verified
+	// before logging in.
+	AuthEmailNotVerified Code = "AUTH_EMAIL_NOT_VERIFIED"
+	// AuthTooManyAttempts means logins for the account or client are
+	// throttled; see the Retry-After header.
+	AuthTooManyAttempts Code = "AUTH_TOO_MANY_ATTEMPTS"
+	// AuthInvalidMFAToken means the token from a two-factor login
+	// challenge is invalid or expired; log in again.
+	AuthInvalidMFAToken Code = "AUTH_INVALID_MFA_TOKEN"
+	// AuthInvalidRefreshToken means the refresh token is unknown.
+	AuthInvalidRefreshToken Code = "AUTH_INVALID_REFRESH_TOKEN"
+	// AuthRefreshTokenExpired means the refresh token is too old; log in
+	// again.
+	AuthRefreshTokenExpired Code = "AUTH_REFRESH_TOKEN_EXPIRED"
+	// AuthRefreshTokenReused means a rotated refresh token was presented
+	// again. The whole session is revoked.
+	AuthRefreshTokenReused Code = "AUTH_REFRESH_TOKEN_REUSED"
+	// AuthInvalidVerificationToken means the email verification link is
+	// invalid, expired or already used.
+	AuthInvalidVerificationToken Code = "AUTH_INVALID_VERIFICATION_TOKEN"
+	// AuthInvalidResetToken means the password reset link is invalid,
+	// expired or already used.
+	AuthInvalidResetToken Code = "AUTH_INVALID_RESET_TOKEN"
+	// AuthInsufficientScope means the personal access token was not
+	// granted the scope the request needs.
+	AuthInsufficientScope Code = "AUTH_INSUFFICIENT_SCOPE"
+	// AuthSessionRequired means the request must be made with an access
+	// token from a login, not a personal access token.
+	AuthSessionRequired Code = "AUTH_SESSION_REQUIRED"
+
+	// UserNotFound means the user does not exist or is not visible to the
+	// caller.
+	UserNotFound Code = "USER_NOT_FOUND"
+	// UserEmailExists means another account already uses the email. It is
+	// reported like a validation failure, against the email field.
+	UserEmailExists Code = "USER_EMAIL_EXISTS"
+	// PasswordIncorrect means the current password given to confirm a
+	// change is wrong.
+	PasswordIncorrect Code = "PASSWORD_INCORRECT"
+	// PasswordPolicyViolation means a new password does not meet the
+	// password policy; the errors list has each rule it breaks.
+	PasswordPolicyViolation Code = "PASSWORD_POLICY_VIOLATION"
+
+	// MFAAlreadyEnabled means two-factor authentication is already on.
+	MFAAlreadyEnabled Code = "MFA_ALREADY_ENABLED"
+	// MFANotEnabled means two-factor authentication is off.
+	MFANotEnabled Code = "MFA_NOT_ENABLED"
+	// MFASetupNotStarted means a code was confirmed before setup began.
+	MFASetupNotStarted Code = "MFA_SETUP_NOT_STARTED"
+	// MFAInvalidCode means the TOTP or recovery code is wrong or was
+	// already used.
+	MFAInvalidCode Code = "MFA_INVALID_CODE"
+
+	// AccessTokenNotFound means the personal access token does not exist,
+	// belongs to someone else or was already revoked.
+	AccessTokenNotFound Code = "ACCESS_TOKEN_NOT_FOUND"
+
+	// OrgNotFound means the organisation does not exist or the caller is
+	// not a member.
+	OrgNotFound Code = "ORG_NOT_FOUND"
+	// OrgRoleTooHigh means the caller tried to grant a role above their
+	// own.
+	OrgRoleTooHigh Code = "ORG_ROLE_TOO_HIGH"
+	// OrgMemberRoleConflict means the user is already a member with a
+	// different role.
+	OrgMemberRoleConflict Code = "ORG_MEMBER_ROLE_CONFLICT"
+)
+
+type entry struct {
+	Status  int
+	Message string
+}
+
+var catalogue = map[Code]entry{
+	ValidationFailed: {http.StatusUnprocessableEntity, "Validation failed"},
+	MalformedRequest: {http.StatusBadRequest, "Request body is not valid JSON"},
+	NotFound:         {http.StatusNotFound, "Not found"},
+	RateLimited:      {http.StatusTooManyRequests, "Rate limit exceeded, try again later"},
+	Forbidden:        {http.StatusForbidden, "You do not have permission to perform this action"},
+	InternalError:    {http.StatusInternalServerError, "Internal server error"},
+	ServerBusy:       {http.StatusServiceUnavailable, "Server is busy, try again shortly"},
+
+	AuthMissingToken:             {http.StatusUnauthorized, "Missing authorization header"},
+	AuthInvalidToken:             {http.StatusUnauthorized, "Invalid token"},
+	AuthTokenRevoked:             {http.StatusUnauthorized, "Token has been revoked"},
+	AuthInvalidCredentials:       {http.StatusUnauthorized, "Authentication failed"},
+	AuthEmailNotVerified:         {http.StatusForbidden, "Email address not verified"},
+	AuthTooManyAttempts:          {http.StatusTooManyRequests, "Too many failed login attempts, try again later"},
+	AuthInvalidMFAToken:          {http.StatusUnauthorized, "Invalid or expired MFA token"},
+	AuthInvalidRefreshToken:      {http.StatusUnauthorized, "Invalid refresh token"},
+	AuthRefreshTokenExpired:      {http.StatusUnauthorized, "Refresh token expired"},
+	AuthRefreshTokenReused:       {http.StatusUnauthorized, "Refresh token reuse detected"},
+	AuthInvalidVerificationToken: {http.StatusBadRequest, "Invalid or expired verification token"},
+	AuthInvalidResetToken:        {http.StatusBadRequest, "Invalid or expired reset token"},
+	AuthInsufficientScope:        {http.StatusForbidden, "Token does not have the scope this request needs"},
+	AuthSessionRequired:          {http.StatusForbidden, "This request cannot be made with a personal access token"},
+
+	UserNotFound:            {http.StatusNotFound, "User not found"},
+	UserEmailExists:         {http.StatusUnprocessableEntity, "Email already exists"},
+	PasswordIncorrect:       {http.StatusUnprocessableEntity, "Password is incorrect"},
+	PasswordPolicyViolation: {http.StatusUnprocessableEntity, "Password does not meet the password policy"},
+
+	MFAAlreadyEnabled:  {http.StatusConflict, "Two-factor authentication is already enabled"},
+	MFANotEnabled:      {http.StatusBadRequest, "Two-factor authentication is not enabled"},
+	MFASetupNotStarted: {http.StatusBadRequest, "Two-factor setup has not been started"},
+	MFAInvalidCode:     {http.StatusBadRequest, "Invalid two-factor code"},
+
+	AccessTokenNotFound: {http.StatusNotFound, "Access token not found"},
+
+	OrgNotFound:           {http.StatusNotFound, "Organisation not found"},
+	OrgRoleTooHigh:        {http.StatusForbidden, "You cannot grant a role higher than your own"},
+	OrgMemberRoleConflict: {http.StatusConflict, "User is already a member with a different role"},
+}
+
+// CatalogueEntry documents one error code.
+type CatalogueEntry struct {
+	Code    Code   `json:"code"`
+	Status  int    `json:"statusCode"`
+	Message string `json:"message"`
+}
+
+// Catalogue lists every error code with its status and default message,
+// sorted by code.
+func Catalogue() []CatalogueEntry {
+	entries := make([]CatalogueEntry, 0, len(catalogue))
+	for code, e := range catalogue {
+		entries = append(entries, CatalogueEntry{Code: code, Status: e.Status, Message: e.Message})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
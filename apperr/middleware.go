@@ -0,0 +1,51 @@
+package apperr
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+type response struct {
+	Status     string       `json:"status"`
+	Code       Code         `json:"code"`
+	Message    string       `json:"message"`
+	StatusCode int          `json:"statusCode"`
+	Errors     []FieldError `json:"errors,omitempty"`
+}
+
+// Abort stops the handler chain and renders err as the response. The error
+// is also recorded on the context so logging middleware can see it.
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+	render(c, From(err))
+}
+
+// Middleware renders the last error a handler reported with c.Error when
+// nothing else wrote a response, so stray errors still use the envelope.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		render(c, From(c.Errors.Last().Err))
+	}
+}
+
+// render writes e as the response. Internal errors are logged with their
+// cause and shown without it.
+func render(c *gin.Context, e *Error) {
+	if e.Status >= 500 {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, e)
+	}
+	c.JSON(e.Status, response{
+		Status:     "error",
+		Code:       e.Code,
+		Message:    e.Message,
+		StatusCode: e.Status,
+		Errors:     e.Fields,
+	})
+}
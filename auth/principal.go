@@ -0,0 +1,76 @@
+// Package auth describes the caller a request was authenticated as.
+package auth
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Method is how the caller of a request authenticated.
+type Method string
+
+const (
+	// MethodSession is a JWT access token issued by a login.
+	MethodSession Method = "session"
+	// MethodAccessToken is a personal access token.
+	MethodAccessToken Method = "access_token"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID string
+	// Email is only known for MethodSession.
+	Email  string
+	Method Method
+	// TokenID identifies the token the caller presented: the jti of an
+	// access token or the ID of a personal access token. ExpiresAt is when
+	// that token expires, and zero for a personal access token that never
+	// does.
+	TokenID   string
+	ExpiresAt time.Time
+	// Scopes limits what a personal access token may do. Sessions may do
+	// anything.
+	Scopes []string
+}
+
+// Allows reports whether the caller was granted scope.
+func (p *Principal) Allows(scope string) bool {
+	if p.Method != MethodAccessToken {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const principalKey = "principal"
+
+// SetPrincipal records p as the caller of the request.
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// CurrentPrincipal returns the caller of the request, if it was
+// authenticated.
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	p, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := p.(*Principal)
+	return principal, ok
+}
+
+// MustPrincipal returns the caller of the request. It panics on routes
+// that do not require authentication.
+func MustPrincipal(c *gin.Context) *Principal {
+	p, ok := CurrentPrincipal(c)
+	if !ok {
+		panic("auth: request has no principal")
+	}
+	return p
+}
@@ -0,0 +1,550 @@
+// Package config loads the service's settings from defaults, an optional
+// YAML or TOML file, a .env file and the environment, in increasing order
+// of precedence.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	JWT       JWTConfig       `yaml:"jwt" toml:"jwt"`
+	Mail      MailConfig      `yaml:"mail" toml:"mail"`
+	App       AppConfig       `yaml:"app" toml:"app"`
+	Password  PasswordConfig  `yaml:"password" toml:"password"`
+	Lockout   LockoutConfig   `yaml:"lockout" toml:"lockout"`
+	RateLimit RateLimitConfig `yaml:"rateLimit" toml:"rateLimit"`
+}
+
+type ServerConfig struct {
+	Addr              string   `yaml:"addr" toml:"addr"`
+	ReadTimeout       Duration `yaml:"readTimeout" toml:"readTimeout"`
+	ReadHeaderTimeout Duration `yaml:"readHeaderTimeout" toml:"readHeaderTimeout"`
+	WriteTimeout      Duration `yaml:"writeTimeout" toml:"writeTimeout"`
+	IdleTimeout       Duration `yaml:"idleTimeout" toml:"idleTimeout"`
+	// ShutdownTimeout is how long in-flight requests get to finish after a
+	// shutdown signal.
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+	MaxHeaderBytes  int      `yaml:"maxHeaderBytes" toml:"maxHeaderBytes"`
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string `yaml:"tlsCertFile" toml:"tlsCertFile"`
+	TLSKeyFile  string `yaml:"tlsKeyFile" toml:"tlsKeyFile"`
+	// TrustedProxies are the addresses or CIDR ranges whose
+	// X-Forwarded-For header is believed. With none, the client address
+	// is always the connection's peer.
+	TrustedProxies []string `yaml:"trustedProxies" toml:"trustedProxies"`
+}
+
+// Duration is a time.Duration written as a string such as "15s" in config
+// files.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslMode" toml:"sslMode"`
+	// MigrateOnStart applies pending migrations when the server starts.
+	MigrateOnStart bool `yaml:"migrateOnStart" toml:"migrateOnStart"`
+}
+
+// DSN returns the Postgres connection string.
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		dsnValue(d.Host), dsnValue(d.User), dsnValue(d.Password), dsnValue(d.Name), d.Port, dsnValue(d.SSLMode))
+}
+
+// dsnValue quotes v for a keyword/value connection string, so spaces,
+// quotes and backslashes in it survive.
+func dsnValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// JWTConfig selects the token signing keys. With KeyFiles set, tokens are
+// signed with those PEM keys; otherwise with HS256 using Secret.
+type JWTConfig struct {
+	Secret    string   `yaml:"secret" toml:"secret"`
+	KeyFiles  []string `yaml:"keyFiles" toml:"keyFiles"`
+	ActiveKID string   `yaml:"activeKid" toml:"activeKid"`
+	// Issuer and Audience are stamped on every token and required of
+	// tokens presented to us.
+	Issuer   string `yaml:"issuer" toml:"issuer"`
+	Audience string `yaml:"audience" toml:"audience"`
+	// ClockSkew is how far token timestamps may be off from our clock.
+	ClockSkew Duration `yaml:"clockSkew" toml:"clockSkew"`
+}
+
+type MailConfig struct {
+	// Driver is "log" to write mail to Dir, or "smtp".
+	Driver string     `yaml:"driver" toml:"driver"`
+	Dir    string     `yaml:"dir" toml:"dir"`
+	From   string     `yaml:"from" toml:"from"`
+	SMTP   SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+type AppConfig struct {
+	// URL is where links in emails point.
+	URL        string `yaml:"url" toml:"url"`
+	TOTPIssuer string `yaml:"totpIssuer" toml:"totpIssuer"`
+}
+
+type PasswordConfig struct {
+	MinLength int `yaml:"minLength" toml:"minLength"`
+	// MaxLength is in bytes. Zero picks the algorithm's default, see
+	// MaxPasswordLength.
+	MaxLength     int    `yaml:"maxLength" toml:"maxLength"`
+	HistorySize   int    `yaml:"historySize" toml:"historySize"`
+	RequireUpper  bool   `yaml:"requireUpper" toml:"requireUpper"`
+	RequireLower  bool   `yaml:"requireLower" toml:"requireLower"`
+	RequireDigit  bool   `yaml:"requireDigit" toml:"requireDigit"`
+	RequireSymbol bool   `yaml:"requireSymbol" toml:"requireSymbol"`
+	BlocklistFile string `yaml:"blocklistFile" toml:"blocklistFile"`
+
+	// Algorithm hashes new passwords: "argon2id" or "bcrypt". Stored
+	// hashes from the other algorithm, or weaker parameters, are upgraded
+	// when their owner logs in.
+	Algorithm  string `yaml:"algorithm" toml:"algorithm"`
+	BcryptCost int    `yaml:"bcryptCost" toml:"bcryptCost"`
+	// Argon2Memory is in KiB.
+	Argon2Memory      int `yaml:"argon2Memory" toml:"argon2Memory"`
+	Argon2Iterations  int `yaml:"argon2Iterations" toml:"argon2Iterations"`
+	Argon2Parallelism int `yaml:"argon2Parallelism" toml:"argon2Parallelism"`
+
+	// HashConcurrency is how many passwords are hashed at once, with 0
+	// meaning one per CPU. Up to HashQueueDepth more wait their turn;
+	// beyond that requests are turned away with 503.
+	HashConcurrency int `yaml:"hashConcurrency" toml:"hashConcurrency"`
+	HashQueueDepth  int `yaml:"hashQueueDepth" toml:"hashQueueDepth"`
+}
+
+type LockoutConfig struct {
+	// Store is "postgres" to share failed logins between instances, or
+	// "memory".
+	Store string `yaml:"store" toml:"store"`
+}
+
+// RateLimitConfig holds each route group's limit in requests per minute.
+type RateLimitConfig struct {
+	Auth          int `yaml:"auth" toml:"auth"`
+	Users         int `yaml:"users" toml:"users"`
+	Organisations int `yaml:"organisations" toml:"organisations"`
+	Admin         int `yaml:"admin" toml:"admin"`
+	WellKnown     int `yaml:"wellKnown" toml:"wellKnown"`
+}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:              ":10000",
+			ReadTimeout:       Duration{15 * time.Second},
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			WriteTimeout:      Duration{30 * time.Second},
+			IdleTimeout:       Duration{2 * time.Minute},
+			ShutdownTimeout:   Duration{20 * time.Second},
+			MaxHeaderBytes:    64 << 10,
+		},
+		Database: DatabaseConfig{Port: 5432, SSLMode: "disable", MigrateOnStart: true},
+		JWT:      JWTConfig{Issuer: "hng", Audience: "hng-api", ClockSkew: Duration{30 * time.Second}},
+		Mail:     MailConfig{Driver: "log", SMTP: SMTPConfig{Port: 587}},
+		App:      AppConfig{URL: "http://localhost:10000", TOTPIssuer: "HNG"},
+		Password: PasswordConfig{
+			MinLength:    8,
+			HistorySize:  3,
+			RequireLower: true,
+			RequireDigit: true,
+
+			Algorithm:         "argon2id",
+			BcryptCost:        14,
+			Argon2Memory:      64 * 1024,
+			Argon2Iterations:  3,
+			Argon2Parallelism: 2,
+
+			HashQueueDepth: 64,
+		},
+		Lockout:   LockoutConfig{Store: "postgres"},
+		RateLimit: RateLimitConfig{Auth: 30, Users: 120, Organisations: 120, Admin: 60, WellKnown: 60},
+	}
+}
+
+// Load builds the configuration and validates it. CONFIG_FILE names an
+// optional .yaml, .yml or .toml file. Variables in .env are applied unless
+// already set in the environment.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(".env: %w", err)
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("%s: unsupported config file type", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) loadEnv() error {
+	strs := map[string]*string{
+		"HTTP_ADDR":               &cfg.Server.Addr,
+		"TLS_CERT_FILE":           &cfg.Server.TLSCertFile,
+		"TLS_KEY_FILE":            &cfg.Server.TLSKeyFile,
+		"POSTGRES_HOST":           &cfg.Database.Host,
+		"POSTGRES_USER":           &cfg.Database.User,
+		"POSTGRES_PASSWORD":       &cfg.Database.Password,
+		"POSTGRES_DB":             &cfg.Database.Name,
+		"POSTGRES_SSLMODE":        &cfg.Database.SSLMode,
+		"JWT_SECRET":              &cfg.JWT.Secret,
+		"JWT_ACTIVE_KID":          &cfg.JWT.ActiveKID,
+		"JWT_ISSUER":              &cfg.JWT.Issuer,
+		"JWT_AUDIENCE":            &cfg.JWT.Audience,
+		"MAILER":                  &cfg.Mail.Driver,
+		"MAIL_DIR":                &cfg.Mail.Dir,
+		"MAIL_FROM":               &cfg.Mail.From,
+		"SMTP_HOST":               &cfg.Mail.SMTP.Host,
+		"SMTP_USERNAME":           &cfg.Mail.SMTP.Username,
+		"SMTP_PASSWORD":           &cfg.Mail.SMTP.Password,
+		"APP_URL":                 &cfg.App.URL,
+		"TOTP_ISSUER":             &cfg.App.TOTPIssuer,
+		"PASSWORD_BLOCKLIST_FILE": &cfg.Password.BlocklistFile,
+		"PASSWORD_ALGORITHM":      &cfg.Password.Algorithm,
+		"LOCKOUT_STORE":           &cfg.Lockout.Store,
+	}
+	for name, dst := range strs {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+
+	ints := map[string]*int{
+		"HTTP_MAX_HEADER_BYTES":       &cfg.Server.MaxHeaderBytes,
+		"POSTGRES_PORT":               &cfg.Database.Port,
+		"SMTP_PORT":                   &cfg.Mail.SMTP.Port,
+		"PASSWORD_MIN_LENGTH":         &cfg.Password.MinLength,
+		"PASSWORD_MAX_LENGTH":         &cfg.Password.MaxLength,
+		"PASSWORD_HISTORY_SIZE":       &cfg.Password.HistorySize,
+		"PASSWORD_BCRYPT_COST":        &cfg.Password.BcryptCost,
+		"PASSWORD_ARGON2_MEMORY":      &cfg.Password.Argon2Memory,
+		"PASSWORD_ARGON2_ITERATIONS":  &cfg.Password.Argon2Iterations,
+		"PASSWORD_ARGON2_PARALLELISM": &cfg.Password.Argon2Parallelism,
+		"PASSWORD_HASH_CONCURRENCY":   &cfg.Password.HashConcurrency,
+		"PASSWORD_HASH_QUEUE_DEPTH":   &cfg.Password.HashQueueDepth,
+		"RATE_LIMIT_AUTH":             &cfg.RateLimit.Auth,
+		"RATE_LIMIT_USERS":            &cfg.RateLimit.Users,
+		"RATE_LIMIT_ORGANISATIONS":    &cfg.RateLimit.Organisations,
+		"RATE_LIMIT_ADMIN":            &cfg.RateLimit.Admin,
+		"RATE_LIMIT_WELL_KNOWN":       &cfg.RateLimit.WellKnown,
+	}
+	for name, dst := range ints {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid value %q", name, v)
+			}
+			*dst = n
+		}
+	}
+
+	durations := map[string]*Duration{
+		"HTTP_READ_TIMEOUT":        &cfg.Server.ReadTimeout,
+		"HTTP_READ_HEADER_TIMEOUT": &cfg.Server.ReadHeaderTimeout,
+		"HTTP_WRITE_TIMEOUT":       &cfg.Server.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        &cfg.Server.IdleTimeout,
+		"HTTP_SHUTDOWN_TIMEOUT":    &cfg.Server.ShutdownTimeout,
+		"JWT_CLOCK_SKEW":           &cfg.JWT.ClockSkew,
+	}
+	for name, dst := range durations {
+		if v := os.Getenv(name); v != "" {
+			if err := dst.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("%s: invalid duration %q", name, v)
+			}
+		}
+	}
+
+	bools := map[string]*bool{
+		"DB_MIGRATE_ON_START":     &cfg.Database.MigrateOnStart,
+		"PASSWORD_REQUIRE_UPPER":  &cfg.Password.RequireUpper,
+		"PASSWORD_REQUIRE_LOWER":  &cfg.Password.RequireLower,
+		"PASSWORD_REQUIRE_DIGIT":  &cfg.Password.RequireDigit,
+		"PASSWORD_REQUIRE_SYMBOL": &cfg.Password.RequireSymbol,
+	}
+	for name, dst := range bools {
+		if v := os.Getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid value %q", name, v)
+			}
+			*dst = b
+		}
+	}
+
+	if v := os.Getenv("JWT_KEY_FILES"); v != "" {
+		cfg.JWT.KeyFiles = splitList(v)
+	}
+	if v, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.Server.TrustedProxies = splitList(v)
+	}
+
+	return nil
+}
+
+// splitList splits a comma-separated variable, dropping empty entries.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// minSecretLength is the shortest HS256 secret accepted, matching the
+// hash size.
+const minSecretLength = 32
+
+// bcryptMaxLength is the longest password, in bytes, bcrypt will hash.
+// Passwords hashed with argon2id may be up to defaultMaxLength unless
+// configured otherwise.
+const (
+	bcryptMaxLength  = 72
+	defaultMaxLength = 256
+)
+
+// MaxPasswordLength is MaxLength, or when that is unset the longest
+// password the algorithm hashes by default.
+func (p PasswordConfig) MaxPasswordLength() int {
+	switch {
+	case p.MaxLength != 0:
+		return p.MaxLength
+	case p.Algorithm == "bcrypt":
+		return bcryptMaxLength
+	default:
+		return defaultMaxLength
+	}
+}
+
+// Validate reports every missing or invalid setting at once, naming both
+// the file key and the environment variable for each.
+func (cfg *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.Server.Addr == "" {
+		fail("server.addr (HTTP_ADDR) is required")
+	}
+	// Slices rather than maps keep the messages in a stable order.
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"server.readTimeout (HTTP_READ_TIMEOUT)", cfg.Server.ReadTimeout},
+		{"server.readHeaderTimeout (HTTP_READ_HEADER_TIMEOUT)", cfg.Server.ReadHeaderTimeout},
+		{"server.writeTimeout (HTTP_WRITE_TIMEOUT)", cfg.Server.WriteTimeout},
+		{"server.idleTimeout (HTTP_IDLE_TIMEOUT)", cfg.Server.IdleTimeout},
+		{"server.shutdownTimeout (HTTP_SHUTDOWN_TIMEOUT)", cfg.Server.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value.Duration <= 0 {
+			fail("%s must be positive", t.name)
+		}
+	}
+	if cfg.Server.MaxHeaderBytes < 1 {
+		fail("server.maxHeaderBytes (HTTP_MAX_HEADER_BYTES) must be at least 1")
+	}
+	if (cfg.Server.TLSCertFile == "") != (cfg.Server.TLSKeyFile == "") {
+		fail("server.tlsCertFile (TLS_CERT_FILE) and server.tlsKeyFile (TLS_KEY_FILE) must be set together")
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				fail("server.trustedProxies (TRUSTED_PROXIES) %q is not an IP address or CIDR range", proxy)
+			}
+		}
+	}
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"database.host (POSTGRES_HOST)", cfg.Database.Host},
+		{"database.user (POSTGRES_USER)", cfg.Database.User},
+		{"database.name (POSTGRES_DB)", cfg.Database.Name},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			fail("%s is required", r.name)
+		}
+	}
+	if cfg.Database.Port <= 0 || cfg.Database.Port > 65535 {
+		fail("database.port (POSTGRES_PORT) must be a valid port, got %d", cfg.Database.Port)
+	}
+	switch cfg.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		fail("database.sslMode (POSTGRES_SSLMODE) %q is not a valid sslmode", cfg.Database.SSLMode)
+	}
+
+	if len(cfg.JWT.KeyFiles) == 0 {
+		if cfg.JWT.Secret == "" {
+			fail("jwt.secret (JWT_SECRET) is required unless jwt.keyFiles (JWT_KEY_FILES) is set")
+		} else if len(cfg.JWT.Secret) < minSecretLength {
+			fail("jwt.secret (JWT_SECRET) must be at least %d bytes", minSecretLength)
+		}
+	}
+	if cfg.JWT.Issuer == "" {
+		fail("jwt.issuer (JWT_ISSUER) is required")
+	}
+	if cfg.JWT.Audience == "" {
+		fail("jwt.audience (JWT_AUDIENCE) is required")
+	}
+	if cfg.JWT.ClockSkew.Duration < 0 {
+		fail("jwt.clockSkew (JWT_CLOCK_SKEW) must not be negative")
+	}
+
+	switch cfg.Mail.Driver {
+	case "log":
+	case "smtp":
+		if cfg.Mail.SMTP.Host == "" {
+			fail("mail.smtp.host (SMTP_HOST) is required when mail.driver is smtp")
+		}
+		if cfg.Mail.From == "" {
+			fail("mail.from (MAIL_FROM) is required when mail.driver is smtp")
+		}
+	default:
+		fail("mail.driver (MAILER) must be log or smtp, got %q", cfg.Mail.Driver)
+	}
+
+	if u, err := url.Parse(cfg.App.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		fail("app.url (APP_URL) must be an absolute URL, got %q", cfg.App.URL)
+	}
+
+	if cfg.Password.MinLength < 1 {
+		fail("password.minLength (PASSWORD_MIN_LENGTH) must be at least 1")
+	}
+	if cfg.Password.MaxPasswordLength() < cfg.Password.MinLength {
+		fail("password.maxLength (PASSWORD_MAX_LENGTH) must be at least password.minLength (PASSWORD_MIN_LENGTH)")
+	}
+	if cfg.Password.HistorySize < 0 {
+		fail("password.historySize (PASSWORD_HISTORY_SIZE) must not be negative")
+	}
+	if cfg.Password.HashConcurrency < 0 {
+		fail("password.hashConcurrency (PASSWORD_HASH_CONCURRENCY) must not be negative")
+	}
+	if cfg.Password.HashQueueDepth < 0 {
+		fail("password.hashQueueDepth (PASSWORD_HASH_QUEUE_DEPTH) must not be negative")
+	}
+	switch cfg.Password.Algorithm {
+	case "argon2id":
+		if cfg.Password.Argon2Iterations < 1 {
+			fail("password.argon2Iterations (PASSWORD_ARGON2_ITERATIONS) must be at least 1")
+		}
+		if cfg.Password.Argon2Parallelism < 1 || cfg.Password.Argon2Parallelism > 255 {
+			fail("password.argon2Parallelism (PASSWORD_ARGON2_PARALLELISM) must be between 1 and 255")
+		}
+		if cfg.Password.Argon2Memory < 8*cfg.Password.Argon2Parallelism {
+			fail("password.argon2Memory (PASSWORD_ARGON2_MEMORY) must be at least 8 KiB per unit of parallelism")
+		}
+	case "bcrypt":
+		if cfg.Password.BcryptCost < 4 || cfg.Password.BcryptCost > 31 {
+			fail("password.bcryptCost (PASSWORD_BCRYPT_COST) must be between 4 and 31")
+		}
+		if cfg.Password.MaxPasswordLength() > bcryptMaxLength {
+			fail("password.maxLength (PASSWORD_MAX_LENGTH) must be at most %d with bcrypt", bcryptMaxLength)
+		}
+	default:
+		fail("password.algorithm (PASSWORD_ALGORITHM) must be argon2id or bcrypt, got %q", cfg.Password.Algorithm)
+	}
+
+	if cfg.Lockout.Store != "postgres" && cfg.Lockout.Store != "memory" {
+		fail("lockout.store (LOCKOUT_STORE) must be postgres or memory, got %q", cfg.Lockout.Store)
+	}
+
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"rateLimit.auth (RATE_LIMIT_AUTH)", cfg.RateLimit.Auth},
+		{"rateLimit.users (RATE_LIMIT_USERS)", cfg.RateLimit.Users},
+		{"rateLimit.organisations (RATE_LIMIT_ORGANISATIONS)", cfg.RateLimit.Organisations},
+		{"rateLimit.admin (RATE_LIMIT_ADMIN)", cfg.RateLimit.Admin},
+		{"rateLimit.wellKnown (RATE_LIMIT_WELL_KNOWN)", cfg.RateLimit.WellKnown},
+	}
+	for _, l := range limits {
+		if l.value < 1 {
+			fail("%s must be at least 1", l.name)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"hng/apperr"
+	"hng/auth"
+	"hng/models"
+	"hng/repository"
+	"hng/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAccessToken issues a personal access token for scripts that call
+// the API as the caller. The token is only ever shown in this response.
+func (h *Handler) CreateAccessToken(c *gin.Context) {
+	var input struct {
+		Name   string   `json:"name" binding:"required,max=100"`
+		Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=read write"`
+		// ExpiresInDays is left out for a token that never expires.
+		ExpiresInDays int `json:"expiresInDays" binding:"omitempty,min=1,max=365"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	token, err := utils.GenerateAccessToken()
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not create access token"))
+		return
+	}
+
+	pat := models.PersonalAccessToken{
+		TokenID:   utils.GenerateUUID(),
+		UserID:    auth.MustPrincipal(c).UserID,
+		Name:      input.Name,
+		TokenHash: utils.HashToken(token),
+		Scopes:    strings.Join(uniqueScopes(input.Scopes), " "),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		pat.ExpiresAt = &expiresAt
+	}
+
+	if err := h.Repos.AccessTokens.Create(c.Request.Context(), &pat); err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not create access token"))
+		return
+	}
+
+	response := models.NewAccessTokenResponse(pat)
+	response.Token = token
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "message": "Access token created. Copy it now, it will not be shown again", "data": response})
+}
+
+// ListAccessTokens lists the caller's unrevoked personal access tokens.
+func (h *Handler) ListAccessTokens(c *gin.Context) {
+	tokens, err := h.Repos.AccessTokens.ListForUser(c.Request.Context(), auth.MustPrincipal(c).UserID)
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not retrieve access tokens"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Access tokens found", "data": gin.H{"tokens": models.NewAccessTokenResponses(tokens)}})
+}
+
+// RevokeAccessToken revokes one of the caller's personal access tokens.
+func (h *Handler) RevokeAccessToken(c *gin.Context) {
+	err := h.Repos.AccessTokens.Revoke(c.Request.Context(), auth.MustPrincipal(c).UserID, c.Param("tokenId"))
+	if err == repository.ErrNotFound {
+		apperr.Abort(c, apperr.New(apperr.AccessTokenNotFound))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not revoke access token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Access token revoked"})
+}
+
+// uniqueScopes drops repeated scopes, keeping the first of each.
+func uniqueScopes(scopes []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, s := range scopes {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
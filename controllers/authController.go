@@ -1,77 +1,109 @@
 package controllers
 
 import (
+	"hng/apperr"
 	"hng/models"
+	"hng/repository"
 	"hng/utils"
 	"log"
 
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
-
-	"gorm.io/gorm"
 )
 
-func hashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return string(bytes)
-}
-
-
-func Register(c *gin.Context) {
-
-	db := c.MustGet("db").(*gorm.DB)
-
+func (h *Handler) Register(c *gin.Context) {
 	var input models.User
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": utils.ValidationErrors(err)})
+	if !bindJSON(c, &input) {
 		return
 	}
 
-
-	// hashPassword(input.Password)
 	input.UserID = utils.GenerateUUID()
 	organisation := models.Organisation{
 		OrgID:       utils.GenerateUUID(),
 		Name:        input.FirstName + "'s Organisation",
 		Description: "Default organisation for " + input.FirstName,
-		Users:       []models.User{input},
 	}
 
-	if err := db.Create(&organisation).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": "Bad request", "message": "Registration unsuccessful. email exist", "statusCode": 400})
+	// The new user owns their default organisation.
+	err := h.Credentials.Register(c.Request.Context(), &input, &organisation)
+	if err == repository.ErrDuplicateEmail {
+		apperr.Abort(c, apperr.New(apperr.UserEmailExists).WithFields(apperr.FieldError{Field: "email", Message: "Email already exists"}))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, passwordError(c, err, "Registration unsuccessful"))
 		return
 	}
 
-	token, _ := utils.GenerateToken(input.Email)
-	c.JSON(http.StatusCreated, gin.H{"status": "success", "message": "Registration successful", "data": gin.H{"accessToken": token, "user": input}})
+	// The account stays inactive until the address is verified, so no
+	// tokens are issued here. A failed send can be retried through
+	// ResendVerification.
+	if err := h.sendVerificationEmail(c.Request.Context(), input); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "message": "Registration successful", "data": gin.H{"user": models.NewUserResponse(input)}})
 }
 
+func (h *Handler) Login(c *gin.Context) {
+	var input struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
 
-func Login(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
+	// Throttled attempts are turned away before the password hash is
+	// checked, so they cost us nothing.
+	if !h.checkLockout(c, input.Email) {
+		return
+	}
 
-	var input models.User
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": utils.ValidationErrors(err)})
+	user, err := h.Repos.Users.FindByEmail(c.Request.Context(), input.Email)
+	if err != nil {
+		h.recordLoginFailure(c, input.Email)
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidCredentials))
 		return
 	}
 
-	var user models.User
-	if err := db.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"status": "Bad request", "message": "Authentication failed", "statusCode": 401})
+	ok, err := h.Credentials.Authenticate(c.Request.Context(), user, input.Password)
+	if err != nil {
+		apperr.Abort(c, passwordError(c, err, "Could not process login"))
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, input.Email)
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidCredentials))
 		return
 	}
 
-	if !utils.CheckPasswordHash(input.Password, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"status": "Bad request", "message": "Authentication failed", "statusCode": 401})
+	if !user.EmailVerified {
+		apperr.Abort(c, apperr.New(apperr.AuthEmailNotVerified))
 		return
 	}
 
-	token, _ := utils.GenerateToken(user.Email)
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login successful", "data": gin.H{"accessToken": token, "user": user}})
-}
\ No newline at end of file
+	// With two-factor enabled the password only earns a short-lived token
+	// that LoginMFA exchanges for real tokens. Failures are only cleared
+	// once both factors pass, so logging in again with the password does
+	// not buy more guesses at the code.
+	if user.TOTPEnabled {
+		mfaToken, _, err := utils.GenerateActionToken(utils.PurposeMFA, user.UserID, user.Email, utils.MFATokenTTL)
+		if err != nil {
+			apperr.Abort(c, apperr.Internal(err, "Could not issue tokens"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Two-factor authentication required", "data": gin.H{"mfaRequired": true, "mfaToken": mfaToken}})
+		return
+	}
+	h.recordLoginSuccess(c, user.Email)
+
+	data, err := h.issueTokens(c, *user, "")
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not issue tokens"))
+		return
+	}
+	data["user"] = models.NewUserResponse(*user)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login successful", "data": data})
+}
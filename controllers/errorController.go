@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"hng/apperr"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCatalogue lists every error code the API can return so clients can
+// check they handle them all.
+func ErrorCatalogue(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Error codes found", "data": gin.H{"errors": apperr.Catalogue()}})
+}
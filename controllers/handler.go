@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"hng/apperr"
+	"hng/config"
+	"hng/credentials"
+	"hng/lockout"
+	"hng/mailer"
+	"hng/repository"
+	"hng/utils"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	ut "github.com/go-playground/universal-translator"
+)
+
+// Handler serves the HTTP endpoints. Everything the handlers depend on is
+// set here rather than passed through the gin context, so tests can swap in
+// in-memory implementations.
+type Handler struct {
+	Repos       *repository.Repositories
+	Credentials *credentials.Service
+	Revocations *utils.RevocationStore
+	Mailer      mailer.Mailer
+	Lockout     *lockout.Guard
+	Config      *config.Config
+
+	background sync.WaitGroup
+}
+
+// inBackground runs fn after the handler returns, with a context that is
+// not cancelled with the request, and logs its error as failing to do
+// what. Work whose duration would reveal something, like whether an
+// account exists, is done this way so every response takes as long.
+func (h *Handler) inBackground(ctx context.Context, what string, fn func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		if err := fn(ctx); err != nil {
+			log.Printf("Error %s: %v", what, err)
+		}
+	}()
+}
+
+// Wait blocks until the work handlers started in the background is done.
+func (h *Handler) Wait() {
+	h.background.Wait()
+}
+
+func (h *Handler) appURL() string {
+	return strings.TrimSuffix(h.Config.App.URL, "/")
+}
+
+// bindJSON binds the request body into input. It reports the rejected
+// fields, in the client's language, and returns false if the body is
+// invalid.
+func bindJSON(c *gin.Context, input interface{}) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		fields := utils.ValidationErrors(err, translator(c))
+		if len(fields) == 0 {
+			apperr.Abort(c, apperr.New(apperr.MalformedRequest))
+		} else {
+			apperr.Abort(c, apperr.Invalid(fields...))
+		}
+		return false
+	}
+	return true
+}
+
+// translator returns the validation messages for the client's
+// Accept-Language.
+func translator(c *gin.Context) ut.Translator {
+	return utils.Translator(c.GetHeader("Accept-Language"))
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"hng/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public signing keys in the standard key set format so
+// other services can verify tokens without sharing a secret.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": utils.JWKS(utils.Keys())})
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics reports load figures for operators, such as how long password
+// hashing requests wait for a worker.
+func (h *Handler) Metrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Metrics found", "data": gin.H{
+		"passwordHashing": h.Credentials.PoolStats(),
+	}})
+}
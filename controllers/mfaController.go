@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"hng/apperr"
+	"hng/auth"
+	"hng/models"
+	"hng/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const recoveryCodeCount = 10
+
+// SetupTwoFactor starts TOTP enrollment by generating a secret. It is not
+// enforced until ConfirmTwoFactor sees a valid code for it.
+func (h *Handler) SetupTwoFactor(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := auth.MustPrincipal(c).UserID
+
+	user, err := h.Repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.UserNotFound))
+		return
+	}
+	if user.TOTPEnabled {
+		apperr.Abort(c, apperr.New(apperr.MFAAlreadyEnabled))
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err == nil {
+		err = h.Repos.TwoFactor.SetSecret(ctx, userID, secret)
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not start two-factor setup"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Scan the code with your authenticator app and confirm it", "data": gin.H{
+		"secret":     secret,
+		"otpauthUri": utils.TOTPURI(h.Config.App.TOTPIssuer, user.Email, secret),
+	}})
+}
+
+// ConfirmTwoFactor enables TOTP once the user proves their authenticator
+// works, and returns recovery codes. The codes are only ever shown here.
+func (h *Handler) ConfirmTwoFactor(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := auth.MustPrincipal(c).UserID
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	user, err := h.Repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.UserNotFound))
+		return
+	}
+	if user.TOTPEnabled {
+		apperr.Abort(c, apperr.New(apperr.MFAAlreadyEnabled))
+		return
+	}
+	if user.TOTPSecret == "" {
+		apperr.Abort(c, apperr.New(apperr.MFASetupNotStarted))
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, input.Code, time.Now())
+	if !ok {
+		apperr.Abort(c, apperr.New(apperr.MFAInvalidCode))
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err == nil {
+		err = h.Repos.TwoFactor.Enable(ctx, userID, step, hashRecoveryCodes(codes))
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not enable two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Two-factor authentication enabled", "data": gin.H{"recoveryCodes": codes}})
+}
+
+// DisableTwoFactor turns TOTP off. It needs both the password and a second
+// factor so a stolen session alone cannot remove it.
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := auth.MustPrincipal(c).UserID
+
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	user, err := h.Repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.UserNotFound))
+		return
+	}
+	if !user.TOTPEnabled {
+		apperr.Abort(c, apperr.New(apperr.MFANotEnabled))
+		return
+	}
+
+	// Guesses through a stolen session count against the same limits as
+	// logins.
+	if !h.checkLockout(c, user.Email) {
+		return
+	}
+
+	ok, err := h.Credentials.Verify(ctx, user, input.Password)
+	if err != nil {
+		apperr.Abort(c, passwordError(c, err, "Could not disable two-factor authentication"))
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email)
+		apperr.Abort(c, apperr.New(apperr.PasswordIncorrect).WithFields(apperr.FieldError{Field: "password", Message: "Password is incorrect"}))
+		return
+	}
+
+	ok, err = h.verifySecondFactor(c, *user, input.Code)
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not disable two-factor authentication"))
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email)
+		apperr.Abort(c, apperr.New(apperr.MFAInvalidCode))
+		return
+	}
+	h.recordLoginSuccess(c, user.Email)
+
+	if err := h.Repos.TwoFactor.Disable(ctx, userID); err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not disable two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Two-factor authentication disabled"})
+}
+
+// LoginMFA completes a login that Login left pending because the account
+// has two-factor authentication enabled.
+func (h *Handler) LoginMFA(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfaToken" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	claims, err := utils.ValidateActionToken(input.MFAToken, utils.PurposeMFA)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidMFAToken))
+		return
+	}
+
+	user, err := h.Repos.Users.FindByID(c.Request.Context(), claims.Subject)
+	if err != nil || !user.TOTPEnabled {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidCredentials))
+		return
+	}
+
+	// Codes are short, so guesses count against the same limits as
+	// passwords.
+	if !h.checkLockout(c, user.Email) {
+		return
+	}
+
+	ok, err := h.verifySecondFactor(c, *user, input.Code)
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not verify two-factor code"))
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email)
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidCredentials))
+		return
+	}
+
+	h.recordLoginSuccess(c, user.Email)
+
+	data, err := h.issueTokens(c, *user, "")
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not issue tokens"))
+		return
+	}
+	data["user"] = models.NewUserResponse(*user)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Login successful", "data": data})
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code. Both are consumed so they cannot be replayed.
+func (h *Handler) verifySecondFactor(c *gin.Context, user models.User, code string) (bool, error) {
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		return h.Repos.TwoFactor.UseStep(c.Request.Context(), user.UserID, step)
+	}
+	return h.Repos.TwoFactor.UseRecoveryCode(c.Request.Context(), user.UserID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	return hashes
+}
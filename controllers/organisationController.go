@@ -1,89 +1,104 @@
 package controllers
 
 import (
+	"hng/apperr"
+	"hng/auth"
 	"hng/models"
 	"hng/utils"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func GetOrganisations(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
-	
-	var organisations []models.Organisation
-	if err := db.Find(&organisations).Error; err != nil {
-		log.Printf("Error retrieving organisations: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "Internal server error", "message": "Could not retrieve organisations"})
+func (h *Handler) GetOrganisations(c *gin.Context) {
+	userID := auth.MustPrincipal(c).UserID
+
+	organisations, err := h.Repos.Organisations.ListForUser(c.Request.Context(), userID)
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not retrieve organisations"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organisations found", "data": gin.H{"organisations": organisations}})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organisations found", "data": gin.H{"organisations": models.NewOrganisationResponses(organisations)}})
 }
 
-func GetOrganisation(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
-	orgID := c.Param("orgId")
-
-	var organisation models.Organisation
-	if err := db.First(&organisation, "org_id = ?", orgID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"status": "Bad request", "message": "Organisation not found", "statusCode": 404})
+func (h *Handler) GetOrganisation(c *gin.Context) {
+	organisation, err := h.Repos.Organisations.FindByOrgID(c.Request.Context(), c.Param("orgId"))
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.OrgNotFound))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organisation found", "data": organisation})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Organisation found", "data": models.NewOrganisationResponse(*organisation)})
 }
 
-func CreateOrganisation(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
+func (h *Handler) CreateOrganisation(c *gin.Context) {
+	ctx := c.Request.Context()
 	var input models.Organisation
 
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": utils.ValidationErrors(err)})
+	if !bindJSON(c, &input) {
 		return
 	}
 
 	input.OrgID = utils.GenerateUUID()
-	userID := c.MustGet("userId").(string)
+	userID := auth.MustPrincipal(c).UserID
 
-	var user models.User
-	db.First(&user, "user_id = ?", userID)
+	user, err := h.Repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.UserNotFound))
+		return
+	}
 
-	if err := db.Create(&input).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": "Bad request", "message": "Organisation creation unsuccessful", "statusCode": 400})
+	// The creator becomes the organisation's owner.
+	if err := h.Repos.Organisations.Create(ctx, &input, user); err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Organisation creation unsuccessful"))
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"status": "success", "message": "Organisation created successfully", "data": input})
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "message": "Organisation created successfully", "data": models.NewOrganisationResponse(input)})
 }
 
-func AddUserToOrganisation(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
-	orgID := c.Param("orgId")
+func (h *Handler) AddUserToOrganisation(c *gin.Context) {
+	ctx := c.Request.Context()
+	caller := c.MustGet("membership").(models.Membership)
 
 	var input struct {
-		UserID string `json:"userId"`
+		UserID string `json:"userId" binding:"required"`
+		Role   string `json:"role" binding:"omitempty,oneof=owner admin member viewer"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+	if input.Role == "" {
+		input.Role = models.RoleMember
 	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": utils.ValidationErrors(err)})
+
+	if !caller.CanGrant(input.Role) {
+		apperr.Abort(c, apperr.New(apperr.OrgRoleTooHigh))
 		return
 	}
 
-	var user models.User
-	if err := db.First(&user, "user_id = ?", input.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"status": "Bad request", "message": "User not found", "statusCode": 404})
+	user, err := h.Repos.Users.FindByID(ctx, input.UserID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.UserNotFound))
 		return
 	}
 
-	var organisation models.Organisation
-	if err := db.First(&organisation, "org_id = ?", orgID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"status": "Bad request", "message": "Organisation not found", "statusCode": 404})
+	existing, err := h.Repos.Organisations.AddMember(ctx, models.Membership{OrganisationID: caller.OrganisationID, UserID: user.ID, Role: input.Role})
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not add user to organisation"))
 		return
 	}
 
-	db.Model(&organisation).Association("Users").Append(&user)
+	// Adding an existing member is a no-op as long as the role matches.
+	if existing != nil {
+		if existing.Role != input.Role {
+			apperr.Abort(c, apperr.New(apperr.OrgMemberRoleConflict))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success", "message": "User is already a member of this organisation"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "User added to organisation successfully"})
 }
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hng/apperr"
+	"hng/auth"
+	"hng/credentials"
+	"hng/mailer"
+	"hng/models"
+	"hng/repository"
+	"hng/utils"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForgotPassword mails a reset link when the address belongs to an account.
+// It answers identically, and as quickly, either way so it cannot be used to
+// enumerate users.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if user, err := h.Repos.Users.FindByEmail(c.Request.Context(), input.Email); err == nil {
+		h.inBackground(c.Request.Context(), "sending password reset email", func(ctx context.Context) error {
+			return h.sendPasswordResetEmail(ctx, *user)
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+func (h *Handler) sendPasswordResetEmail(ctx context.Context, user models.User) error {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	// Only the most recent link stays usable.
+	err = h.Repos.PasswordResets.Create(ctx, &models.PasswordReset{
+		TokenHash: utils.HashToken(token),
+		UserID:    user.UserID,
+		ExpiresAt: time.Now().Add(utils.PasswordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := h.appURL() + "/reset-password?token=" + url.QueryEscape(token)
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. If it was you, open the link below:\n\n%s\n\nThe link expires in %s. If you did not ask for a reset you can ignore this email.\n",
+			user.FirstName, link, utils.PasswordResetTTL),
+	})
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs the
+// user out everywhere.
+func (h *Handler) ResetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	reset, err := h.Repos.PasswordResets.FindValid(ctx, utils.HashToken(input.Token))
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidResetToken))
+		return
+	}
+
+	err = h.Credentials.Reset(ctx, reset, "password", input.Password)
+	if err == repository.ErrTokenUsed {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidResetToken))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, passwordError(c, err, "Could not reset password"))
+		return
+	}
+
+	if err := h.revokeUserSessions(c, reset.UserID); err != nil {
+		log.Printf("Error revoking sessions after password reset: %v", err)
+	}
+	// A reset is how a compromised account is recovered, so personal access
+	// tokens go too, unlike on a logout.
+	if err := h.Repos.AccessTokens.RevokeAllForUser(ctx, reset.UserID); err != nil {
+		log.Printf("Error revoking access tokens after password reset: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password reset successfully"})
+}
+
+// ChangePassword sets a new password for the caller after confirming the
+// current one.
+func (h *Handler) ChangePassword(c *gin.Context) {
+	userID := auth.MustPrincipal(c).UserID
+
+	var input struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	user, err := h.Repos.Users.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.UserNotFound))
+		return
+	}
+
+	// Guesses through a stolen session count against the same limits as
+	// logins.
+	if !h.checkLockout(c, user.Email) {
+		return
+	}
+
+	ok, err := h.Credentials.Verify(c.Request.Context(), user, input.CurrentPassword)
+	if err != nil {
+		apperr.Abort(c, passwordError(c, err, "Could not change password"))
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(c, user.Email)
+		apperr.Abort(c, apperr.New(apperr.PasswordIncorrect).WithFields(apperr.FieldError{Field: "currentPassword", Message: "Current password is incorrect"}))
+		return
+	}
+	h.recordLoginSuccess(c, user.Email)
+
+	if err := h.Credentials.Change(c.Request.Context(), userID, "newPassword", input.NewPassword); err != nil {
+		apperr.Abort(c, passwordError(c, err, "Could not change password"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password changed successfully"})
+}
+
+// passwordError reports the rules a new password breaks, or that the
+// hashing pool could not take the request, or err as an internal error
+// with message if handling the password failed otherwise.
+func passwordError(c *gin.Context, err error, message string) *apperr.Error {
+	if _, ok := err.(*utils.PasswordPolicyError); ok {
+		return apperr.New(apperr.PasswordPolicyViolation).WithFields(utils.ValidationErrors(err, translator(c))...)
+	}
+	if errors.Is(err, credentials.ErrBusy) || errors.Is(err, credentials.ErrClosed) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		c.Header("Retry-After", "1")
+		return apperr.New(apperr.ServerBusy)
+	}
+	return apperr.Internal(err, message)
+}
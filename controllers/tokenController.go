@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"hng/apperr"
+	"hng/auth"
+	"hng/models"
+	"hng/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// issueTokens signs an access token for user and stores a new refresh token
+// in familyID. An empty familyID starts a new family.
+func (h *Handler) issueTokens(c *gin.Context, user models.User, familyID string) (gin.H, error) {
+	accessToken, err := utils.GenerateToken(user.UserID, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		familyID = utils.GenerateUUID()
+	}
+
+	record := models.RefreshToken{
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  familyID,
+		UserID:    user.UserID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL),
+	}
+	if err := h.Repos.RefreshTokens.Create(c.Request.Context(), &record); err != nil {
+		return nil, err
+	}
+
+	return gin.H{"accessToken": accessToken, "refreshToken": refreshToken}, nil
+}
+
+func (h *Handler) Refresh(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	token, err := h.Repos.RefreshTokens.FindByHash(ctx, utils.HashToken(input.RefreshToken))
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidRefreshToken))
+		return
+	}
+
+	// A token that was already rotated or revoked is being replayed, so
+	// whoever holds the rest of the chain can no longer be trusted.
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		if err := h.Repos.RefreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+			apperr.Abort(c, apperr.Internal(err, "Could not refresh token"))
+			return
+		}
+		apperr.Abort(c, apperr.New(apperr.AuthRefreshTokenReused))
+		return
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		apperr.Abort(c, apperr.New(apperr.AuthRefreshTokenExpired))
+		return
+	}
+
+	// Two concurrent refreshes cannot both claim the token.
+	claimed, err := h.Repos.RefreshTokens.MarkUsed(ctx, token.ID)
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not refresh token"))
+		return
+	}
+	if !claimed {
+		if err := h.Repos.RefreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+			apperr.Abort(c, apperr.Internal(err, "Could not refresh token"))
+			return
+		}
+		apperr.Abort(c, apperr.New(apperr.AuthRefreshTokenReused))
+		return
+	}
+
+	user, err := h.Repos.Users.FindByID(ctx, token.UserID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidRefreshToken))
+		return
+	}
+
+	tokens, err := h.issueTokens(c, *user, token.FamilyID)
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not refresh token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Token refreshed", "data": tokens})
+}
+
+func (h *Handler) Logout(c *gin.Context) {
+	ctx := c.Request.Context()
+	principal := auth.MustPrincipal(c)
+
+	var input struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if c.Request.ContentLength > 0 {
+		if !bindJSON(c, &input) {
+			return
+		}
+	}
+
+	if err := h.Revocations.RevokeToken(principal.TokenID, principal.ExpiresAt); err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not log out"))
+		return
+	}
+
+	// The refresh token identifies the session; revoke its family so it
+	// cannot be used to mint new access tokens.
+	if input.RefreshToken != "" {
+		token, err := h.Repos.RefreshTokens.FindByHash(ctx, utils.HashToken(input.RefreshToken))
+		if err == nil && token.UserID == principal.UserID {
+			if err := h.Repos.RefreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+				apperr.Abort(c, apperr.Internal(err, "Could not log out"))
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Logged out successfully"})
+}
+
+func (h *Handler) LogoutAll(c *gin.Context) {
+	principal := auth.MustPrincipal(c)
+
+	if err := h.revokeUserSessions(c, principal.UserID); err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not revoke sessions"))
+		return
+	}
+
+	// A token issued within the same microsecond as the cutoff, or within
+	// the same second if it predates sub-second issue times, is not
+	// covered by the session revocation, so revoke the caller's token
+	// explicitly.
+	if err := h.Revocations.RevokeToken(principal.TokenID, principal.ExpiresAt); err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not revoke sessions"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "All sessions revoked"})
+}
+
+// revokeUserSessions invalidates every access and refresh token issued to
+// userID so far.
+func (h *Handler) revokeUserSessions(c *gin.Context, userID string) error {
+	if err := h.Revocations.RevokeUser(userID, time.Now()); err != nil {
+		return err
+	}
+	return h.Repos.RefreshTokens.RevokeAllForUser(c.Request.Context(), userID)
+}
@@ -1,22 +1,34 @@
 package controllers
 
 import (
-	"net/http"
+	"hng/apperr"
+	"hng/auth"
 	"hng/models"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func GetUser(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
+// GetUser returns the caller's own record or that of a user sharing at least
+// one organisation with them. Anyone else is reported as not found.
+func (h *Handler) GetUser(c *gin.Context) {
+	ctx := c.Request.Context()
 	userID := c.Param("id")
+	callerID := auth.MustPrincipal(c).UserID
 
-	var user models.User
-	if err := db.First(&user, "user_id = ?", userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"status": "Bad request", "message": "User not found", "statusCode": 404})
+	user, err := h.Repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.UserNotFound))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "User found", "data": user})
+	if userID != callerID {
+		shared, err := h.Repos.Organisations.ShareOrganisation(ctx, callerID, userID)
+		if err != nil || !shared {
+			apperr.Abort(c, apperr.New(apperr.UserNotFound))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "User found", "data": models.NewUserResponse(*user)})
 }
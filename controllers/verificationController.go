@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hng/apperr"
+	"hng/mailer"
+	"hng/models"
+	"hng/repository"
+	"hng/utils"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendVerificationEmail issues a new verification token for user and mails
+// it. Only the most recent token stays usable.
+func (h *Handler) sendVerificationEmail(ctx context.Context, user models.User) error {
+	token, claims, err := utils.GenerateActionToken(utils.PurposeVerifyEmail, user.UserID, user.Email, utils.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	verification := models.EmailVerification{
+		JTI:       claims.Id,
+		UserID:    user.UserID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}
+	if err := h.Repos.EmailVerifications.Create(ctx, &verification); err != nil {
+		return err
+	}
+
+	link := h.appURL() + "/verify-email?token=" + url.QueryEscape(token)
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.FirstName, link, utils.EmailVerificationTTL),
+	})
+}
+
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	claims, err := utils.ValidateActionToken(input.Token, utils.PurposeVerifyEmail)
+	if err != nil {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidVerificationToken))
+		return
+	}
+
+	err = h.Repos.EmailVerifications.Redeem(c.Request.Context(), claims.Id, claims.Subject)
+	if err == repository.ErrTokenUsed {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidVerificationToken))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not verify email"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Email verified successfully"})
+}
+
+// ResendVerification always answers the same way, and as quickly, so it
+// cannot be used to find out which addresses are registered.
+func (h *Handler) ResendVerification(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	user, err := h.Repos.Users.FindByEmail(c.Request.Context(), input.Email)
+	if err == nil && !user.EmailVerified {
+		h.inBackground(c.Request.Context(), "resending verification email", func(ctx context.Context) error {
+			return h.sendVerificationEmail(ctx, *user)
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "If the account exists and is not verified, a verification email has been sent"})
+}
@@ -0,0 +1,150 @@
+// Package credentials owns users' passwords. A password is hashed exactly
+// once, when it is set, and only through this package; nothing else writes
+// the stored hash.
+package credentials
+
+import (
+	"context"
+	"hng/models"
+	"hng/repository"
+	"hng/utils"
+	"log"
+)
+
+// Service sets, changes and checks passwords. All hashing runs on pool,
+// so every method that hashes can fail with ErrBusy, ErrClosed or the
+// context's error.
+type Service struct {
+	users  repository.UserRepository
+	resets repository.PasswordResetRepository
+	hasher PasswordHasher
+	pool   *Pool
+}
+
+// NewService hashes new passwords with hasher on pool. Passwords stored by
+// other hashers still verify, and are upgraded to hasher by Authenticate.
+func NewService(repos *repository.Repositories, hasher PasswordHasher, pool *Pool) *Service {
+	return &Service{users: repos.Users, resets: repos.PasswordResets, hasher: hasher, pool: pool}
+}
+
+// PoolStats reports the load on the hashing pool.
+func (s *Service) PoolStats() PoolStats {
+	return s.pool.Stats()
+}
+
+// Register checks user's plain-text password against the policy, replaces
+// it with its hash and stores the user with org as their default
+// organisation. Policy violations are returned as *utils.PasswordPolicyError.
+func (s *Service) Register(ctx context.Context, user *models.User, org *models.Organisation) error {
+	if err := utils.CurrentPasswordPolicy().Validate("password", user.Password); err != nil {
+		return err
+	}
+	hash, err := s.hash(ctx, user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return s.users.Register(ctx, user, org)
+}
+
+// Verify reports whether password is user's password.
+func (s *Service) Verify(ctx context.Context, user *models.User, password string) (bool, error) {
+	var ok bool
+	var err error
+	if poolErr := s.pool.Run(ctx, func() { ok, err = verify(user.Password, password) }); poolErr != nil {
+		return false, poolErr
+	}
+	if err != nil {
+		log.Printf("Error verifying password of user %s: %v", user.UserID, err)
+	}
+	return ok, nil
+}
+
+// Authenticate verifies password like Verify. When it matches a hash made
+// with an older algorithm or weaker parameters, the hash is replaced with
+// one from the current hasher while the plain-text password is at hand.
+func (s *Service) Authenticate(ctx context.Context, user *models.User, password string) (bool, error) {
+	ok, err := s.Verify(ctx, user, password)
+	if err != nil || !ok {
+		return false, err
+	}
+	if s.hasher.NeedsRehash(user.Password) {
+		// A failed upgrade is retried on the next login.
+		if err := s.rehash(ctx, user, password); err != nil {
+			log.Printf("Error upgrading password hash of user %s: %v", user.UserID, err)
+		}
+	}
+	return true, nil
+}
+
+func (s *Service) rehash(ctx context.Context, user *models.User, password string) error {
+	hash, err := s.hash(ctx, password)
+	if err != nil {
+		return err
+	}
+	if err := s.users.ReplacePasswordHash(ctx, user.UserID, user.Password, hash); err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// Change sets a new password for userID. field names the request field the
+// password came from, for policy violations.
+func (s *Service) Change(ctx context.Context, userID, field, password string) error {
+	hash, err := s.newHash(ctx, userID, field, password)
+	if err != nil {
+		return err
+	}
+	return s.users.SetPassword(ctx, userID, hash, utils.CurrentPasswordPolicy().HistorySize)
+}
+
+// Reset redeems reset and sets a new password for its user. It returns
+// repository.ErrTokenUsed if the reset was redeemed first.
+func (s *Service) Reset(ctx context.Context, reset *models.PasswordReset, field, password string) error {
+	hash, err := s.newHash(ctx, reset.UserID, field, password)
+	if err != nil {
+		return err
+	}
+	return s.resets.Redeem(ctx, reset, hash, utils.CurrentPasswordPolicy().HistorySize)
+}
+
+// newHash applies the password policy to a new password for userID,
+// including the check against the user's recent passwords, and hashes it.
+func (s *Service) newHash(ctx context.Context, userID, field, password string) (string, error) {
+	policy := utils.CurrentPasswordPolicy()
+	if err := policy.Validate(field, password); err != nil {
+		return "", err
+	}
+	if policy.HistorySize > 0 {
+		hashes, err := s.users.RecentPasswordHashes(ctx, userID, policy.HistorySize)
+		if err != nil {
+			return "", err
+		}
+		var reuseErr error
+		if err := s.pool.Run(ctx, func() { reuseErr = policy.CheckReuse(field, password, hashes, matches) }); err != nil {
+			return "", err
+		}
+		if reuseErr != nil {
+			return "", reuseErr
+		}
+	}
+	return s.hash(ctx, password)
+}
+
+// hash hashes password with the current hasher on the pool.
+func (s *Service) hash(ctx context.Context, password string) (string, error) {
+	var hash string
+	var err error
+	if poolErr := s.pool.Run(ctx, func() { hash, err = s.hasher.Hash(password) }); poolErr != nil {
+		return "", poolErr
+	}
+	return hash, err
+}
+
+// matches reports whether password produced hash. Unrecognised hashes never
+// match.
+func matches(password, hash string) bool {
+	ok, _ := verify(hash, password)
+	return ok
+}
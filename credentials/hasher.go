@@ -0,0 +1,238 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hng/config"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownHash is returned for a stored hash no hasher recognises.
+var ErrUnknownHash = errors.New("credentials: unrecognised password hash")
+
+// PasswordHasher hashes passwords into self-describing strings in PHC
+// format, so a stored hash names the algorithm and parameters it was made
+// with and keeps verifying after the configuration changes.
+type PasswordHasher interface {
+	// Hash returns a hash of password with a fresh random salt.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash. It returns
+	// ErrUnknownHash if hash is not in this hasher's format.
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether hash was made with another algorithm or
+	// with weaker parameters than this hasher uses.
+	NeedsRehash(hash string) bool
+}
+
+// NewHasher returns the hasher cfg selects for new passwords.
+func NewHasher(cfg config.PasswordConfig) (PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case "argon2id":
+		return &Argon2id{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+			SaltLength:  16,
+			KeyLength:   32,
+		}, nil
+	case "bcrypt":
+		return &Bcrypt{Cost: cfg.BcryptCost}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", cfg.Algorithm)
+	}
+}
+
+// verify checks password against hash with whichever algorithm made it.
+func verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return (&Argon2id{}).Verify(hash, password)
+	case strings.HasPrefix(hash, "$bcrypt$"), isBcryptMCF(hash):
+		return (&Bcrypt{}).Verify(hash, password)
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+// Bcrypt hashes with bcrypt at Cost. Its hashes look like
+//
+//	$bcrypt$r=12$<salt>$<key>
+//
+// with salt and key in unpadded base64. Hashes in bcrypt's own $2a$
+// modular crypt format, as stored before PHC strings were used, still
+// verify but always need a rehash.
+type Bcrypt struct {
+	Cost int
+}
+
+// bcryptEncoding is the base64 alphabet of bcrypt's modular crypt format.
+var bcryptEncoding = base64.NewEncoding("./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789").WithPadding(base64.NoPadding)
+
+const (
+	bcryptSaltLength = 22 // encoded characters of the 16 byte salt
+	bcryptKeyLength  = 23 // bytes of the key
+)
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return toBcryptPHC(string(hash))
+}
+
+func (b *Bcrypt) Verify(hash, password string) (bool, error) {
+	mcf, err := toBcryptMCF(hash)
+	if err != nil {
+		return false, err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(mcf), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Bcrypt) NeedsRehash(hash string) bool {
+	if !strings.HasPrefix(hash, "$bcrypt$") {
+		return true
+	}
+	mcf, err := toBcryptMCF(hash)
+	if err != nil {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(mcf))
+	return err != nil || cost < b.Cost
+}
+
+func isBcryptMCF(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// toBcryptPHC rewrites a $2a$ hash from the bcrypt package as a PHC
+// string.
+func toBcryptPHC(mcf string) (string, error) {
+	cost, err := bcrypt.Cost([]byte(mcf))
+	if err != nil {
+		return "", ErrUnknownHash
+	}
+	parts := strings.Split(mcf, "$")
+	if len(parts) != 4 || len(parts[3]) <= bcryptSaltLength {
+		return "", ErrUnknownHash
+	}
+	salt, err := bcryptEncoding.DecodeString(parts[3][:bcryptSaltLength])
+	if err != nil {
+		return "", ErrUnknownHash
+	}
+	key, err := bcryptEncoding.DecodeString(parts[3][bcryptSaltLength:])
+	if err != nil {
+		return "", ErrUnknownHash
+	}
+	return fmt.Sprintf("$bcrypt$r=%d$%s$%s", cost,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// toBcryptMCF returns the $2a$ form of a PHC bcrypt hash, which is what the
+// bcrypt package verifies, or hash itself if it is a legacy $2a$, $2b$ or
+// $2y$ hash.
+func toBcryptMCF(hash string) (string, error) {
+	if isBcryptMCF(hash) {
+		return hash, nil
+	}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[1] != "bcrypt" {
+		return "", ErrUnknownHash
+	}
+	var cost int
+	if _, err := fmt.Sscanf(parts[2], "r=%d", &cost); err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return "", ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(salt) != 16 {
+		return "", ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(key) != bcryptKeyLength {
+		return "", ErrUnknownHash
+	}
+	return fmt.Sprintf("$2a$%02d$%s%s", cost, bcryptEncoding.EncodeToString(salt), bcryptEncoding.EncodeToString(key)), nil
+}
+
+// Argon2id hashes with argon2id. Memory is in KiB. Its hashes look like
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+//
+// with salt and key in unpadded base64.
+type Argon2id struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) Verify(hash, password string) (bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	derived := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(derived, key) == 1, nil
+}
+
+func (a *Argon2id) NeedsRehash(hash string) bool {
+	params, _, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory < a.Memory || params.Iterations < a.Iterations ||
+		params.Parallelism < a.Parallelism || uint32(len(key)) < a.KeyLength
+}
+
+// parseArgon2id splits a PHC argon2id hash into its parameters, salt and
+// key.
+func parseArgon2id(hash string) (*Argon2id, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	params := &Argon2id{}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,215 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBusy is returned when the hashing queue is full.
+var ErrBusy = errors.New("credentials: password hashing queue is full")
+
+// ErrClosed is returned by Run once the pool has been closed, as it can be
+// while a shutdown that timed out leaves requests running.
+var ErrClosed = errors.New("credentials: password hashing pool is closed")
+
+// waitBuckets are the upper bounds of the queue wait histogram.
+var waitBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Pool runs password hashing on a fixed number of goroutines, so a burst
+// of logins cannot take every core away from other requests. Jobs wait in
+// a bounded queue; once it is full, Run fails fast with ErrBusy.
+type Pool struct {
+	// slots holds one token per admitted job, running or queued, so at
+	// most concurrency+queueDepth jobs are in the pool at once.
+	slots chan struct{}
+	jobs  chan *job
+	wg    sync.WaitGroup
+
+	// closeMu is held for reading while a job is sent to jobs, so Close
+	// cannot close the channel under a sender.
+	closeMu sync.RWMutex
+	closed  bool
+
+	running   atomic.Int64
+	completed atomic.Int64
+	rejected  atomic.Int64
+	cancelled atomic.Int64
+
+	mu      sync.Mutex
+	waits   []int64 // per waitBuckets, plus one for longer waits
+	waitSum time.Duration
+	waitMax time.Duration
+}
+
+type job struct {
+	ctx      context.Context
+	fn       func()
+	queuedAt time.Time
+	done     chan struct{}
+}
+
+// NewPool starts concurrency workers, or one per CPU if concurrency is
+// zero, behind a queue of queueDepth jobs. A queueDepth of zero admits a
+// job only while a worker is free.
+func NewPool(concurrency, queueDepth int) *Pool {
+	if concurrency < 1 {
+		concurrency = runtime.NumCPU()
+	}
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+	p := &Pool{
+		slots: make(chan struct{}, concurrency+queueDepth),
+		jobs:  make(chan *job, concurrency+queueDepth),
+		waits: make([]int64, len(waitBuckets)+1),
+	}
+	p.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Run queues fn and waits for a worker to run it. It returns ErrBusy
+// without queueing if the queue is full, ErrClosed if the pool is closed,
+// and ctx's error if ctx is done first, in which case fn is skipped if no
+// worker has picked it up yet.
+func (p *Pool) Run(ctx context.Context, fn func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.closeMu.RLock()
+	if p.closed {
+		p.closeMu.RUnlock()
+		return ErrClosed
+	}
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		p.closeMu.RUnlock()
+		p.rejected.Add(1)
+		return ErrBusy
+	}
+	// Holding a slot guarantees room in jobs, so this never blocks.
+	j := &job{ctx: ctx, fn: fn, queuedAt: time.Now(), done: make(chan struct{})}
+	p.jobs <- j
+	p.closeMu.RUnlock()
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the workers once the queued jobs have been handled. Later
+// calls to Run fail with ErrClosed.
+func (p *Pool) Close() {
+	p.closeMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.closeMu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for j := range p.jobs {
+		// The client gave up while the job was queued; don't spend a
+		// hash on it.
+		if j.ctx.Err() != nil {
+			p.cancelled.Add(1)
+			<-p.slots
+			continue
+		}
+		p.recordWait(time.Since(j.queuedAt))
+
+		p.running.Add(1)
+		j.fn()
+		p.running.Add(-1)
+		p.completed.Add(1)
+		// Free the slot before waking the caller, so a caller that runs
+		// jobs one after another always finds room.
+		<-p.slots
+		close(j.done)
+	}
+}
+
+func (p *Pool) recordWait(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := 0
+	for i < len(waitBuckets) && d > waitBuckets[i] {
+		i++
+	}
+	p.waits[i]++
+	p.waitSum += d
+	if d > p.waitMax {
+		p.waitMax = d
+	}
+}
+
+// PoolStats is a snapshot of a Pool's counters.
+type PoolStats struct {
+	Queued    int   `json:"queued"`
+	Running   int64 `json:"running"`
+	Completed int64 `json:"completed"`
+	// Rejected jobs found the queue full; Cancelled ones were abandoned
+	// by their request before a worker reached them.
+	Rejected  int64 `json:"rejected"`
+	Cancelled int64 `json:"cancelled"`
+
+	QueueWait WaitStats `json:"queueWait"`
+}
+
+// WaitStats describes how long jobs waited for a worker. Buckets are
+// cumulative: each counts the jobs that waited at most LE seconds.
+type WaitStats struct {
+	Count      int64        `json:"count"`
+	SumSeconds float64      `json:"sumSeconds"`
+	MaxSeconds float64      `json:"maxSeconds"`
+	Buckets    []WaitBucket `json:"buckets"`
+}
+
+type WaitBucket struct {
+	LE    float64 `json:"le"`
+	Count int64   `json:"count"`
+}
+
+// Stats returns the pool's current counters.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	wait := WaitStats{SumSeconds: p.waitSum.Seconds(), MaxSeconds: p.waitMax.Seconds()}
+	for i, bound := range waitBuckets {
+		wait.Count += p.waits[i]
+		wait.Buckets = append(wait.Buckets, WaitBucket{LE: bound.Seconds(), Count: wait.Count})
+	}
+	wait.Count += p.waits[len(waitBuckets)]
+	p.mu.Unlock()
+
+	return PoolStats{
+		Queued:    len(p.jobs),
+		Running:   p.running.Load(),
+		Completed: p.completed.Load(),
+		Rejected:  p.rejected.Load(),
+		Cancelled: p.cancelled.Load(),
+		QueueWait: wait,
+	}
+}
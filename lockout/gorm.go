@@ -0,0 +1,70 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"hng/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore keeps records in the login_attempts table so every instance
+// sees the same counters.
+type GormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) Get(ctx context.Context, key string) (Record, error) {
+	var attempt models.LoginAttempt
+	err := s.db.WithContext(ctx).First(&attempt, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return toRecord(attempt), nil
+}
+
+func (s *GormStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Record, error) {
+	var attempt models.LoginAttempt
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A single upsert keeps concurrent failures from overwriting each
+		// other's counts.
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END", now.Add(-window)),
+				"last_failure_at": now,
+			}),
+		}).Create(&models.LoginAttempt{Key: key, Failures: 1, LastFailureAt: now}).Error
+		if err != nil {
+			return err
+		}
+		return tx.First(&attempt, "key = ?", key).Error
+	})
+	if err != nil {
+		return Record{}, err
+	}
+	return toRecord(attempt), nil
+}
+
+func (s *GormStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", until).Error
+}
+
+func (s *GormStore) Reset(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Delete(&models.LoginAttempt{}, "key = ?", key).Error
+}
+
+func toRecord(a models.LoginAttempt) Record {
+	return Record{Failures: a.Failures, LastFailureAt: a.LastFailureAt, LockedUntil: a.LockedUntil}
+}
@@ -0,0 +1,149 @@
+// Package lockout tracks failed logins per account and per client IP and
+// throttles further attempts with exponential backoff and temporary
+// lockouts.
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Policy controls how one kind of key is throttled. The first FreeAttempts
+// failures cost nothing; each failure after that blocks the key for
+// BaseDelay, doubling per failure up to MaxDelay. Reaching LockoutThreshold
+// locks the key for LockoutDuration. Failures older than Window are
+// forgotten.
+type Policy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	Window           time.Duration
+}
+
+// DefaultAccountPolicy protects a single account from password guessing.
+func DefaultAccountPolicy() Policy {
+	return Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  30 * time.Minute,
+		Window:           time.Hour,
+	}
+}
+
+// DefaultIPPolicy is looser since many users can share an address, but still
+// stops one client spraying passwords across accounts.
+func DefaultIPPolicy() Policy {
+	return Policy{
+		FreeAttempts:     10,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 50,
+		LockoutDuration:  30 * time.Minute,
+		Window:           time.Hour,
+	}
+}
+
+// delay returns how long a key with failures recent failures is blocked.
+func (p Policy) delay(failures int) time.Duration {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < over && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// Guard applies an account and an IP policy on top of a Store.
+type Guard struct {
+	Store   Store
+	Account Policy
+	IP      Policy
+	// Now is used instead of time.Now when set, for tests.
+	Now func() time.Time
+}
+
+func NewGuard(store Store) *Guard {
+	return &Guard{Store: store, Account: DefaultAccountPolicy(), IP: DefaultIPPolicy()}
+}
+
+func (g *Guard) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns how long the caller must wait before another attempt for
+// email from ip is allowed. Zero means the attempt may go ahead.
+func (g *Guard) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := g.now()
+	var wait time.Duration
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		r, err := g.Store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if d := r.LockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt for email from ip and blocks either key
+// once its policy says so.
+func (g *Guard) Fail(ctx context.Context, email, ip string) error {
+	now := g.now()
+	for _, k := range []struct {
+		key    string
+		policy Policy
+	}{
+		{accountKey(email), g.Account},
+		{ipKey(ip), g.IP},
+	} {
+		r, err := g.Store.RecordFailure(ctx, k.key, now, k.policy.Window)
+		if err != nil {
+			return err
+		}
+		if d := k.policy.delay(r.Failures); d > 0 {
+			if err := g.Store.Lock(ctx, k.key, now.Add(d)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Unlock clears the account's failures and any lockout on it.
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+	return g.Store.Reset(ctx, accountKey(email))
+}
+
+// Succeed records a successful login, which unlocks the account. The IP's
+// failures are kept so a valid login cannot be used to reset a credential
+// stuffing run.
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+	return g.Unlock(ctx, email)
+}
@@ -0,0 +1,24 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Record is the failure history of one key, such as an account or an IP.
+type Record struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists failure records. RecordFailure must be atomic so that
+// concurrent failures are all counted.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	// RecordFailure adds a failure at now and returns the updated record.
+	// Failures older than window are forgotten first.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Record, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"hng/config"
+	"strconv"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email such as verification links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New builds the mailer selected by cfg.Driver. "smtp" delivers through
+// cfg.SMTP; "log" logs messages, or writes them to cfg.Dir when it is set,
+// for local testing.
+func New(cfg config.MailConfig) Mailer {
+	if cfg.Driver == "smtp" {
+		return &SMTPMailer{
+			Host:     cfg.SMTP.Host,
+			Port:     strconv.Itoa(cfg.SMTP.Port),
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.From,
+		}
+	}
+	return &LogMailer{Dir: cfg.Dir}
+}
@@ -1,10 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"hng/config"
+	"hng/controllers"
+	"hng/credentials"
+	"hng/lockout"
+	"hng/mailer"
+	"hng/migrations"
 	"hng/models"
+	"hng/ratelimit"
+	"hng/repository"
 	"hng/routes"
+	"hng/server"
+	"hng/utils"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,30 +26,127 @@ import (
 	"gorm.io/gorm"
 )
 
+func openDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{PrepareStmt: false})
+	if err != nil {
+		return nil, err
+	}
+	if err := models.SetupJoinTables(db); err != nil {
+		return nil, fmt.Errorf("setting up join tables: %w", err)
+	}
+	return db, nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	db, err := openDatabase(cfg.Database)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to connect to database: %v", err))
+	}
+
+	if cfg.Database.MigrateOnStart {
+		if err := migrateUp(db); err != nil {
+			panic(fmt.Sprintf("Failed to migrate database: %v", err))
+		}
+	}
+
+	keys, err := utils.LoadKeyProviderFromConfig(cfg.JWT)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load signing keys: %v", err))
+	}
+	utils.SetKeyProvider(keys)
+	utils.ConfigureTokens(cfg.JWT)
 
-	dbUser := os.Getenv("POSTGRES_USER")
-	dbPassword := os.Getenv("POSTGRES_PASSWORD")
-	dbName := os.Getenv("POSTGRES_DB")
-	dbHost := os.Getenv("POSTGRES_HOST")
-	dbPort := os.Getenv("POSTGRES_PORT")
+	policy, err := utils.NewPasswordPolicy(cfg.Password)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load password policy: %v", err))
+	}
+	utils.SetPasswordPolicy(policy)
 
-	// Form the connection string
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		dbHost, dbUser, dbPassword, dbName, dbPort)
-	
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{PrepareStmt: false})
+	hasher, err := credentials.NewHasher(cfg.Password)
 	if err != nil {
-		panic("Failed to connect to database")
+		panic(fmt.Sprintf("Failed to configure password hashing: %v", err))
 	}
+	hashPool := credentials.NewPool(cfg.Password.HashConcurrency, cfg.Password.HashQueueDepth)
 
-	db.AutoMigrate(&models.User{}, &models.Organisation{})
+	repos := repository.NewGorm(db)
+
+	// Failed logins are shared through Postgres unless a single instance
+	// opts into keeping them in memory.
+	var attempts lockout.Store = lockout.NewGormStore(db)
+	if cfg.Lockout.Store == "memory" {
+		attempts = lockout.NewMemoryStore()
+	}
+
+	h := &controllers.Handler{
+		Repos:       repos,
+		Credentials: credentials.NewService(repos, hasher, hashPool),
+		Revocations: utils.NewRevocationStore(repos.Revocations),
+		Mailer:      mailer.New(cfg.Mail),
+		Lockout:     lockout.NewGuard(attempts),
+		Config:      cfg,
+	}
+
+	limiter := ratelimit.NewMemoryBackend()
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("Failed to set trusted proxies: %v", err))
+	}
 
-	routes.AuthRoutes(r, db)
-	routes.UserRoutes(r, db)
-	routes.OrganisationRoutes(r, db)
+	routes.Setup(r, h, limiter)
 
-	r.Run(":10000")
+	// SIGTERM and Ctrl-C stop new connections and let in-flight requests
+	// finish before the database pool is closed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := server.Run(ctx, server.New(cfg.Server, r), cfg.Server)
+	if serveErr != nil {
+		log.Printf("Server error: %v", serveErr)
+	}
+
+	// Mail queued by the last requests still goes out.
+	h.Wait()
+	hashPool.Close()
+
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("Error closing database: %v", err)
+		}
+	}
+
+	if serveErr != nil {
+		os.Exit(1)
+	}
+	log.Print("Server stopped")
+}
+
+func migrateUp(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	m, err := migrations.New(sqlDB, migrations.Files)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Up(context.Background())
+	for _, mig := range applied {
+		log.Printf("Applied migration %04d_%s", mig.Version, mig.Name)
+	}
+	return err
 }
@@ -0,0 +1,59 @@
+-- Tables use IF NOT EXISTS so databases created by the old AutoMigrate
+-- startup adopt this migration without changes.
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id text CONSTRAINT uni_users_user_id UNIQUE,
+    first_name text,
+    last_name text,
+    email text CONSTRAINT uni_users_email UNIQUE,
+    password text,
+    phone text,
+    email_verified boolean NOT NULL DEFAULT false,
+    email_verified_at timestamptz,
+    totp_secret text,
+    totp_enabled boolean NOT NULL DEFAULT false,
+    totp_last_step bigint,
+    is_admin boolean NOT NULL DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+-- A users table from before email verification, two-factor and admins
+-- lacks these columns. email_verified is added without a default first so
+-- the accounts it is added to can be told apart: they predate verification
+-- and are treated as verified rather than locked out.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified boolean;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamptz;
+UPDATE users SET email_verified = true, email_verified_at = created_at WHERE email_verified IS NULL;
+ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT false;
+ALTER TABLE users ALTER COLUMN email_verified SET NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled boolean NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step bigint;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS organisations (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    org_id text CONSTRAINT uni_organisations_org_id UNIQUE,
+    name text,
+    description text
+);
+CREATE INDEX IF NOT EXISTS idx_organisations_deleted_at ON organisations (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_organisations (
+    organisation_id bigint CONSTRAINT fk_user_organisations_organisation REFERENCES organisations (id),
+    user_id bigint CONSTRAINT fk_user_organisations_user REFERENCES users (id),
+    role text NOT NULL DEFAULT 'member',
+    created_at timestamptz,
+    PRIMARY KEY (organisation_id, user_id)
+);
+
+-- Memberships from before roles existed become plain members; 0008 makes
+-- one member of each such organisation its owner.
+ALTER TABLE user_organisations ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'member';
+ALTER TABLE user_organisations ADD COLUMN IF NOT EXISTS created_at timestamptz;
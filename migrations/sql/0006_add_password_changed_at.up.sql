@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at timestamptz;
+
+-- Without a record of later changes, existing passwords date from signup.
+UPDATE users SET password_changed_at = created_at WHERE password_changed_at IS NULL;
@@ -0,0 +1,3 @@
+-- Owners backfilled by the up migration cannot be told apart from owners
+-- who created their organisation, so they keep the role.
+SELECT 1;
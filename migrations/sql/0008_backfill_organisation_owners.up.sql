@@ -0,0 +1,15 @@
+-- Organisations from before roles existed have no owner, and without one
+-- nobody may add members. No creator was recorded, so the first member to
+-- join becomes owner, which for an organisation with one member is that
+-- member. Memberships from before roles have no created_at and sort first,
+-- with the earliest registered user winning among them.
+UPDATE user_organisations uo
+SET role = 'owner'
+FROM (
+    SELECT DISTINCT ON (organisation_id) organisation_id, user_id
+    FROM user_organisations
+    WHERE organisation_id NOT IN (SELECT organisation_id FROM user_organisations WHERE role = 'owner')
+    ORDER BY organisation_id, created_at NULLS FIRST, user_id
+) first_member
+WHERE uo.organisation_id = first_member.organisation_id
+  AND uo.user_id = first_member.user_id;
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerification tracks a signed verification token by its JWT ID so it
+// can only be redeemed once.
+type EmailVerification struct {
+	gorm.Model
+	JTI       string `gorm:"uniqueIndex"`
+	UserID    string `gorm:"index"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
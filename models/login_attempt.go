@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// LoginAttempt is the failed login history of an account or client IP,
+// identified by Key.
+type LoginAttempt struct {
+	Key           string `gorm:"primaryKey"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// roleRank orders roles so members can only grant roles up to their own.
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+type Permission string
+
+const (
+	PermViewOrganisation Permission = "organisation:view"
+	PermAddMember        Permission = "organisation:add_member"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleOwner:  {PermViewOrganisation, PermAddMember},
+	RoleAdmin:  {PermViewOrganisation, PermAddMember},
+	RoleMember: {PermViewOrganisation},
+	RoleViewer: {PermViewOrganisation},
+}
+
+// Membership is the join row between Organisation.Users and User. It is
+// registered as the join table with SetupJoinTables, so OrganisationID and
+// UserID are the numeric primary keys rather than the public UUIDs.
+type Membership struct {
+	OrganisationID uint   `gorm:"primaryKey"`
+	UserID         uint   `gorm:"primaryKey"`
+	Role           string `gorm:"not null;default:member"`
+	CreatedAt      time.Time
+}
+
+func (Membership) TableName() string {
+	return "user_organisations"
+}
+
+func (m *Membership) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.Role == "" {
+		m.Role = RoleMember
+	}
+	return
+}
+
+// Can reports whether the member's role grants perm.
+func (m Membership) Can(perm Permission) bool {
+	for _, p := range rolePermissions[m.Role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanGrant reports whether the member may give another user role.
+func (m Membership) CanGrant(role string) bool {
+	rank, ok := roleRank[role]
+	return ok && rank <= roleRank[m.Role]
+}
+
+// SetupJoinTables must run before migrating or querying organisations so
+// gorm uses Membership for the Organisation.Users association.
+func SetupJoinTables(db *gorm.DB) error {
+	return db.SetupJoinTable(&Organisation{}, "Users", &Membership{})
+}
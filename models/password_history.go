@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// PasswordHistory keeps the hashes of a user's recent passwords, including
+// the current one, so they cannot be reused.
+type PasswordHistory struct {
+	gorm.Model
+	UserID string `gorm:"index"`
+	Hash   string
+}
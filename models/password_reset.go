@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordReset is a single-use reset token. Only the token's hash is
+// stored so a database leak cannot be used to take over accounts.
+type PasswordReset struct {
+	gorm.Model
+	TokenHash string `gorm:"uniqueIndex"`
+	UserID    string `gorm:"index"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
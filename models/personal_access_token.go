@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes a personal access token can be granted. ScopeRead allows safe
+// requests such as GET; ScopeWrite allows the rest.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// PersonalAccessToken lets scripts call the API as a user without their
+// password. Only a hash of the token is stored; the plaintext is shown once
+// when it is created.
+type PersonalAccessToken struct {
+	gorm.Model
+	TokenID   string `gorm:"uniqueIndex"`
+	UserID    string `gorm:"index"`
+	Name      string
+	TokenHash string `gorm:"uniqueIndex"`
+	// Scopes is the space separated list of granted scopes.
+	Scopes     string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// ScopeList returns the token's scopes.
+func (t PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// Expired reports whether the token has an expiry and it has passed.
+func (t PersonalAccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a hashed single-use code that stands in for a TOTP code
+// when the user has lost their authenticator.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   string `gorm:"index"`
+	CodeHash string
+	UsedAt   *time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is one link in a rotation chain. Every token issued from the
+// same login shares a FamilyID so the whole chain can be revoked at once.
+type RefreshToken struct {
+	gorm.Model
+	TokenHash string `gorm:"uniqueIndex"`
+	FamilyID  string `gorm:"index"`
+	UserID    string `gorm:"index"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// UserResponse is the public view of a User. Credentials and internal keys
+// are never part of it, so handlers must render users through it.
+type UserResponse struct {
+	UserID    string `json:"userId"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+
+	EmailVerified bool `json:"emailVerified"`
+}
+
+func NewUserResponse(u User) UserResponse {
+	return UserResponse{
+		UserID:        u.UserID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Email:         u.Email,
+		Phone:         u.Phone,
+		EmailVerified: u.EmailVerified,
+	}
+}
+
+// OrganisationResponse is the public view of an Organisation.
+type OrganisationResponse struct {
+	OrgID       string `json:"orgId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func NewOrganisationResponse(o Organisation) OrganisationResponse {
+	return OrganisationResponse{
+		OrgID:       o.OrgID,
+		Name:        o.Name,
+		Description: o.Description,
+	}
+}
+
+func NewOrganisationResponses(orgs []Organisation) []OrganisationResponse {
+	responses := make([]OrganisationResponse, 0, len(orgs))
+	for _, o := range orgs {
+		responses = append(responses, NewOrganisationResponse(o))
+	}
+	return responses
+}
+
+// AccessTokenResponse is the public view of a PersonalAccessToken. Token,
+// the plaintext, is only set in the response that creates it.
+type AccessTokenResponse struct {
+	TokenID    string     `json:"tokenId"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+func NewAccessTokenResponse(t PersonalAccessToken) AccessTokenResponse {
+	return AccessTokenResponse{
+		TokenID:    t.TokenID,
+		Name:       t.Name,
+		Scopes:     t.ScopeList(),
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+func NewAccessTokenResponses(tokens []PersonalAccessToken) []AccessTokenResponse {
+	responses := make([]AccessTokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		responses = append(responses, NewAccessTokenResponse(t))
+	}
+	return responses
+}
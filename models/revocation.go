@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokedToken blacklists a single access token by its JWT ID until the
+// token would have expired anyway.
+type RevokedToken struct {
+	gorm.Model
+	JTI       string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+}
+
+// SessionRevocation invalidates every access token issued to a user before
+// RevokedBefore.
+type SessionRevocation struct {
+	gorm.Model
+	UserID        string `gorm:"uniqueIndex"`
+	RevokedBefore time.Time
+}
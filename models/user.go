@@ -1,34 +1,35 @@
 package models
 
 import (
-	"log"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
 	UserID    string `gorm:"unique" json:"userId"`
-	FirstName string `json:"firstName" `
-	LastName  string `json:"lastName"`
+	FirstName string `json:"firstName" binding:"required"`
+	LastName  string `json:"lastName" binding:"required"`
 	Email     string `gorm:"unique" json:"email" binding:"required,email"`
 	Password  string `json:"password" binding:"required"`
 	Phone     string `json:"phone"`
-}
-
 
+	// PasswordChangedAt is when Password, a hash once stored, was last
+	// set. Only the credentials package sets passwords.
+	PasswordChangedAt *time.Time `json:"-"`
 
+	EmailVerified   bool       `gorm:"not null;default:false" json:"-"`
+	EmailVerifiedAt *time.Time `json:"-"`
 
-func (u *User) BeforeSave(tx *gorm.DB) (err error) {
-	u.Password = hashPassword(u.Password)
-	return
-}
+	// TOTPSecret is set during enrollment but only enforced once
+	// TOTPEnabled is true. TOTPLastStep is the last accepted time step, so
+	// a code cannot be replayed.
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"-"`
+	TOTPLastStep int64  `json:"-"`
 
-func hashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return string(bytes)
+	// IsAdmin grants access to the /api/admin endpoints. It can only be
+	// set directly in the database.
+	IsAdmin bool `gorm:"not null;default:false" json:"-"`
 }
@@ -0,0 +1,85 @@
+// Package ratelimit provides token bucket rate limiting as gin middleware.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"hng/apperr"
+	"hng/auth"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit allows bursts of up to Requests and refills at Requests per Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Result is the state of a bucket after a Take.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token when Allowed is false.
+	RetryAfter time.Duration
+}
+
+// Backend stores buckets. Take must consume a token atomically.
+type Backend interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// KeyFunc picks the bucket a request draws from.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP gives every client address its own bucket.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser gives every authenticated user their own bucket and falls back to
+// the client address for anonymous requests. It must run after
+// authMiddleware.
+func ByUser(c *gin.Context) string {
+	if p, ok := auth.CurrentPrincipal(c); ok {
+		return "user:" + p.UserID
+	}
+	return ByIP(c)
+}
+
+// Middleware limits requests to limit per bucket. name separates the
+// buckets of different groups sharing a backend. If the backend fails the
+// request is let through rather than taking the API down with it.
+func Middleware(backend Backend, name string, limit Limit, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := backend.Take(c.Request.Context(), name+":"+key(c), limit, time.Now())
+		if err != nil {
+			log.Printf("Error checking rate limit: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", seconds(res.Reset))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Requests, seconds(limit.Per)))
+
+		if !res.Allowed {
+			c.Header("Retry-After", seconds(res.RetryAfter))
+			apperr.Abort(c, apperr.New(apperr.RateLimited))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
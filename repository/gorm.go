@@ -0,0 +1,470 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hng/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGorm returns repositories backed by db. models.SetupJoinTables must
+// have been called on db.
+func NewGorm(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:              &gormUsers{db},
+		Organisations:      &gormOrganisations{db},
+		RefreshTokens:      &gormRefreshTokens{db},
+		AccessTokens:       &gormAccessTokens{db},
+		EmailVerifications: &gormEmailVerifications{db},
+		PasswordResets:     &gormPasswordResets{db},
+		TwoFactor:          &gormTwoFactor{db},
+		Revocations:        &gormRevocations{db},
+	}
+}
+
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r *gormUsers) FindByID(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "user_id = ?", userID).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "email = ?", email).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) Register(ctx context.Context, user *models.User, org *models.Organisation) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", user.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrDuplicateEmail
+		}
+
+		now := time.Now()
+		user.PasswordChangedAt = &now
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Users").Create(org).Error; err != nil {
+			return err
+		}
+		err := tx.Create(&models.Membership{OrganisationID: org.ID, UserID: user.ID, Role: models.RoleOwner}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordHistory{UserID: user.UserID, Hash: user.Password}).Error
+	})
+	// A concurrent registration can pass the check above and make the
+	// insert fail on the unique index instead.
+	if err != nil && err != ErrDuplicateEmail {
+		if _, findErr := r.FindByEmail(ctx, user.Email); findErr == nil {
+			return ErrDuplicateEmail
+		}
+	}
+	return err
+}
+
+func (r *gormUsers) SetPassword(ctx context.Context, userID, hash string, keepHistory int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, userID, hash, keepHistory)
+	})
+}
+
+// setPassword stores hash for userID, appends it to the password history and
+// drops entries beyond keepHistory.
+func setPassword(tx *gorm.DB, userID, hash string, keepHistory int) error {
+	err := tx.Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"password": hash, "password_changed_at": time.Now()}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Create(&models.PasswordHistory{UserID: userID, Hash: hash}).Error; err != nil {
+		return err
+	}
+
+	var stale []uint
+	err = tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Offset(keepHistory).
+		Limit(-1).
+		Pluck("id", &stale).Error
+	if err != nil || len(stale) == 0 {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.PasswordHistory{}, stale).Error
+}
+
+func (r *gormUsers) ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.User{}).
+			Where("user_id = ? AND password = ?", userID, oldHash).
+			UpdateColumn("password", newHash)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Model(&models.PasswordHistory{}).
+			Where("user_id = ? AND hash = ?", userID, oldHash).
+			UpdateColumn("hash", newHash).Error
+	})
+}
+
+func (r *gormUsers) RecentPasswordHashes(ctx context.Context, userID string, n int) ([]string, error) {
+	var hashes []string
+	err := r.db.WithContext(ctx).Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(n).
+		Pluck("hash", &hashes).Error
+	return hashes, err
+}
+
+type gormOrganisations struct{ db *gorm.DB }
+
+func (r *gormOrganisations) FindByOrgID(ctx context.Context, orgID string) (*models.Organisation, error) {
+	var org models.Organisation
+	if err := r.db.WithContext(ctx).First(&org, "org_id = ?", orgID).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &org, nil
+}
+
+func (r *gormOrganisations) ListForUser(ctx context.Context, userID string) ([]models.Organisation, error) {
+	var organisations []models.Organisation
+	err := r.db.WithContext(ctx).
+		Joins("JOIN user_organisations ON user_organisations.organisation_id = organisations.id").
+		Joins("JOIN users ON users.id = user_organisations.user_id AND users.deleted_at IS NULL").
+		Where("users.user_id = ?", userID).
+		Find(&organisations).Error
+	return organisations, err
+}
+
+func (r *gormOrganisations) Create(ctx context.Context, org *models.Organisation, owner *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Users").Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{OrganisationID: org.ID, UserID: owner.ID, Role: models.RoleOwner}).Error
+	})
+}
+
+func (r *gormOrganisations) FindMembership(ctx context.Context, orgID, userID string) (*models.Membership, error) {
+	var membership models.Membership
+	err := r.db.WithContext(ctx).Table("user_organisations").
+		Select("user_organisations.*").
+		Joins("JOIN organisations ON organisations.id = user_organisations.organisation_id AND organisations.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = user_organisations.user_id AND users.deleted_at IS NULL").
+		Where("organisations.org_id = ? AND users.user_id = ?", orgID, userID).
+		Take(&membership).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &membership, nil
+}
+
+func (r *gormOrganisations) AddMember(ctx context.Context, m models.Membership) (*models.Membership, error) {
+	db := r.db.WithContext(ctx)
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&m)
+	if result.Error != nil || result.RowsAffected == 1 {
+		return nil, result.Error
+	}
+
+	var existing models.Membership
+	if err := db.First(&existing, "organisation_id = ? AND user_id = ?", m.OrganisationID, m.UserID).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (r *gormOrganisations) ShareOrganisation(ctx context.Context, userID, otherUserID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table("user_organisations AS mine").
+		Joins("JOIN organisations ON organisations.id = mine.organisation_id AND organisations.deleted_at IS NULL").
+		Joins("JOIN user_organisations AS theirs ON theirs.organisation_id = mine.organisation_id").
+		Joins("JOIN users AS me ON me.id = mine.user_id AND me.deleted_at IS NULL").
+		Joins("JOIN users AS them ON them.id = theirs.user_id AND them.deleted_at IS NULL").
+		Where("me.user_id = ? AND them.user_id = ?", userID, otherUserID).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+type gormRefreshTokens struct{ db *gorm.DB }
+
+func (r *gormRefreshTokens) Create(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *gormRefreshTokens) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r *gormRefreshTokens) MarkUsed(ctx context.Context, id uint) (bool, error) {
+	// The conditional update makes two concurrent refreshes race on the
+	// row, so only one of them can claim the token.
+	result := r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormRefreshTokens) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *gormRefreshTokens) RevokeAllForUser(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+type gormAccessTokens struct{ db *gorm.DB }
+
+func (r *gormAccessTokens) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *gormAccessTokens) ListForUser(ctx context.Context, userID string) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("id").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *gormAccessTokens) FindByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	if err := r.db.WithContext(ctx).First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r *gormAccessTokens) Revoke(ctx context.Context, userID, tokenID string) error {
+	result := r.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("token_id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormAccessTokens) RevokeAllForUser(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *gormAccessTokens) Touch(ctx context.Context, id uint, at time.Time) error {
+	// UpdateColumn leaves UpdatedAt alone, so it keeps meaning the last
+	// change to the token itself.
+	return r.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", at).Error
+}
+
+type gormEmailVerifications struct{ db *gorm.DB }
+
+func (r *gormEmailVerifications) Create(ctx context.Context, v *models.EmailVerification) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.EmailVerification{}).
+			Where("user_id = ? AND used_at IS NULL", v.UserID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(v).Error
+	})
+}
+
+func (r *gormEmailVerifications) Redeem(ctx context.Context, jti, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.EmailVerification{}).
+			Where("jti = ? AND user_id = ? AND used_at IS NULL", jti, userID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenUsed
+		}
+
+		return tx.Model(&models.User{}).
+			Where("user_id = ?", userID).
+			UpdateColumns(map[string]interface{}{"email_verified": true, "email_verified_at": now}).Error
+	})
+}
+
+type gormPasswordResets struct{ db *gorm.DB }
+
+func (r *gormPasswordResets) Create(ctx context.Context, reset *models.PasswordReset) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(reset).Error
+	})
+}
+
+func (r *gormPasswordResets) FindValid(ctx context.Context, tokenHash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&reset).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &reset, nil
+}
+
+func (r *gormPasswordResets) Redeem(ctx context.Context, reset *models.PasswordReset, hash string, keepHistory int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenUsed
+		}
+		return setPassword(tx, reset.UserID, hash, keepHistory)
+	})
+}
+
+type gormTwoFactor struct{ db *gorm.DB }
+
+func (r *gormTwoFactor) SetSecret(ctx context.Context, userID, secret string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ?", userID).
+		UpdateColumn("totp_secret", secret).Error
+}
+
+func (r *gormTwoFactor) Enable(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("user_id = ?", userID).
+			UpdateColumns(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (r *gormTwoFactor) Disable(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("user_id = ?", userID).
+			UpdateColumns(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, nil)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codeHashes []string) error {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: hash}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gormTwoFactor) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ? AND totp_last_step < ?", userID, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *gormTwoFactor) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+type gormRevocations struct{ db *gorm.DB }
+
+func (r *gormRevocations) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	record := models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error
+}
+
+func (r *gormRevocations) RevokeUser(ctx context.Context, userID string, before time.Time) error {
+	record := models.SessionRevocation{UserID: userID, RevokedBefore: before}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "updated_at"}),
+	}).Create(&record).Error
+}
+
+func (r *gormRevocations) Load(ctx context.Context, now time.Time) (map[string]time.Time, map[string]time.Time, error) {
+	db := r.db.WithContext(ctx)
+	if err := db.Unscoped().Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var revokedTokens []models.RevokedToken
+	if err := db.Find(&revokedTokens).Error; err != nil {
+		return nil, nil, err
+	}
+	var revocations []models.SessionRevocation
+	if err := db.Find(&revocations).Error; err != nil {
+		return nil, nil, err
+	}
+
+	tokens := make(map[string]time.Time, len(revokedTokens))
+	for _, t := range revokedTokens {
+		tokens[t.JTI] = t.ExpiresAt
+	}
+	users := make(map[string]time.Time, len(revocations))
+	for _, r := range revocations {
+		users[r.UserID] = r.RevokedBefore
+	}
+	return tokens, users, nil
+}
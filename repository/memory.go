@@ -0,0 +1,558 @@
+package repository
+
+import (
+	"context"
+	"hng/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewMemory returns repositories that keep everything in process memory.
+// They behave like the gorm ones, including single-use tokens, and are
+// meant for tests and local experiments.
+func NewMemory() *Repositories {
+	s := &memoryStore{
+		users:          map[string]*models.User{},
+		organisations:  map[string]*models.Organisation{},
+		memberships:    map[[2]uint]*models.Membership{},
+		refreshTokens:  map[string]*models.RefreshToken{},
+		accessTokens:   map[string]*models.PersonalAccessToken{},
+		verifications:  map[string]*models.EmailVerification{},
+		resets:         map[string]*models.PasswordReset{},
+		revokedTokens:  map[string]time.Time{},
+		revokedUsers:   map[string]time.Time{},
+		recoveryCodes:  map[string][]*models.RecoveryCode{},
+		passwordHashes: map[string][]string{},
+	}
+	return &Repositories{
+		Users:              (*memoryUsers)(s),
+		Organisations:      (*memoryOrganisations)(s),
+		RefreshTokens:      (*memoryRefreshTokens)(s),
+		AccessTokens:       (*memoryAccessTokens)(s),
+		EmailVerifications: (*memoryEmailVerifications)(s),
+		PasswordResets:     (*memoryPasswordResets)(s),
+		TwoFactor:          (*memoryTwoFactor)(s),
+		Revocations:        (*memoryRevocations)(s),
+	}
+}
+
+// memoryStore holds every record behind one lock, so operations spanning
+// several of them are atomic like the gorm transactions. Records are copied
+// in and out so callers cannot change stored state behind the lock.
+type memoryStore struct {
+	mu     sync.Mutex
+	lastID uint
+
+	users          map[string]*models.User // by UserID
+	organisations  map[string]*models.Organisation
+	memberships    map[[2]uint]*models.Membership // by organisation and user ID
+	refreshTokens  map[string]*models.RefreshToken
+	accessTokens   map[string]*models.PersonalAccessToken // by TokenHash
+	verifications  map[string]*models.EmailVerification
+	resets         map[string]*models.PasswordReset
+	revokedTokens  map[string]time.Time
+	revokedUsers   map[string]time.Time
+	recoveryCodes  map[string][]*models.RecoveryCode
+	passwordHashes map[string][]string // newest last
+}
+
+func (s *memoryStore) nextID() uint {
+	s.lastID++
+	return s.lastID
+}
+
+func (s *memoryStore) setPassword(userID, hash string, keepHistory int) {
+	user, ok := s.users[userID]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	user.Password, user.PasswordChangedAt = hash, &now
+	history := append(s.passwordHashes[userID], hash)
+	if len(history) > keepHistory {
+		history = history[len(history)-keepHistory:]
+	}
+	s.passwordHashes[userID] = history
+}
+
+type memoryUsers memoryStore
+
+func (r *memoryUsers) FindByID(ctx context.Context, userID string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+func (r *memoryUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			u := *user
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUsers) Register(ctx context.Context, user *models.User, org *models.Organisation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == user.Email {
+			return ErrDuplicateEmail
+		}
+	}
+
+	s := (*memoryStore)(r)
+	now := time.Now()
+	user.ID, user.CreatedAt, user.UpdatedAt = s.nextID(), now, now
+	user.PasswordChangedAt = &now
+	org.ID, org.CreatedAt, org.UpdatedAt = s.nextID(), now, now
+
+	u, o := *user, *org
+	o.Users = nil
+	r.users[u.UserID] = &u
+	r.organisations[o.OrgID] = &o
+	r.memberships[[2]uint{o.ID, u.ID}] = &models.Membership{OrganisationID: o.ID, UserID: u.ID, Role: models.RoleOwner, CreatedAt: now}
+	r.passwordHashes[u.UserID] = []string{u.Password}
+	return nil
+}
+
+func (r *memoryUsers) SetPassword(ctx context.Context, userID, hash string, keepHistory int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	(*memoryStore)(r).setPassword(userID, hash, keepHistory)
+	return nil
+}
+
+func (r *memoryUsers) ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok || user.Password != oldHash {
+		return ErrNotFound
+	}
+	user.Password = newHash
+	for i, hash := range r.passwordHashes[userID] {
+		if hash == oldHash {
+			r.passwordHashes[userID][i] = newHash
+		}
+	}
+	return nil
+}
+
+func (r *memoryUsers) RecentPasswordHashes(ctx context.Context, userID string, n int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history := r.passwordHashes[userID]
+	var hashes []string
+	for i := len(history) - 1; i >= 0 && len(hashes) < n; i-- {
+		hashes = append(hashes, history[i])
+	}
+	return hashes, nil
+}
+
+type memoryOrganisations memoryStore
+
+func (r *memoryOrganisations) FindByOrgID(ctx context.Context, orgID string) (*models.Organisation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	org, ok := r.organisations[orgID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	o := *org
+	return &o, nil
+}
+
+func (r *memoryOrganisations) ListForUser(ctx context.Context, userID string) ([]models.Organisation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	var organisations []models.Organisation
+	for _, org := range r.organisations {
+		if _, ok := r.memberships[[2]uint{org.ID, user.ID}]; ok {
+			organisations = append(organisations, *org)
+		}
+	}
+	sort.Slice(organisations, func(i, j int) bool { return organisations[i].ID < organisations[j].ID })
+	return organisations, nil
+}
+
+func (r *memoryOrganisations) Create(ctx context.Context, org *models.Organisation, owner *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	org.ID, org.CreatedAt, org.UpdatedAt = (*memoryStore)(r).nextID(), now, now
+
+	o := *org
+	o.Users = nil
+	r.organisations[o.OrgID] = &o
+	r.memberships[[2]uint{o.ID, owner.ID}] = &models.Membership{OrganisationID: o.ID, UserID: owner.ID, Role: models.RoleOwner, CreatedAt: now}
+	return nil
+}
+
+func (r *memoryOrganisations) FindMembership(ctx context.Context, orgID, userID string) (*models.Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	org, ok := r.organisations[orgID]
+	user, ok2 := r.users[userID]
+	if !ok || !ok2 {
+		return nil, ErrNotFound
+	}
+	membership, ok := r.memberships[[2]uint{org.ID, user.ID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	m := *membership
+	return &m, nil
+}
+
+func (r *memoryOrganisations) AddMember(ctx context.Context, m models.Membership) (*models.Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := [2]uint{m.OrganisationID, m.UserID}
+	if existing, ok := r.memberships[key]; ok {
+		e := *existing
+		return &e, nil
+	}
+	if m.Role == "" {
+		m.Role = models.RoleMember
+	}
+	m.CreatedAt = time.Now()
+	r.memberships[key] = &m
+	return nil, nil
+}
+
+func (r *memoryOrganisations) ShareOrganisation(ctx context.Context, userID, otherUserID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	other, ok2 := r.users[otherUserID]
+	if !ok || !ok2 {
+		return false, nil
+	}
+	for _, org := range r.organisations {
+		_, mine := r.memberships[[2]uint{org.ID, user.ID}]
+		_, theirs := r.memberships[[2]uint{org.ID, other.ID}]
+		if mine && theirs {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryRefreshTokens memoryStore
+
+func (r *memoryRefreshTokens) Create(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	token.ID, token.CreatedAt, token.UpdatedAt = (*memoryStore)(r).nextID(), now, now
+	t := *token
+	r.refreshTokens[t.TokenHash] = &t
+	return nil
+}
+
+func (r *memoryRefreshTokens) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.refreshTokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+func (r *memoryRefreshTokens) MarkUsed(ctx context.Context, id uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.refreshTokens {
+		if token.ID == id {
+			if token.UsedAt != nil || token.RevokedAt != nil {
+				return false, nil
+			}
+			now := time.Now()
+			token.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryRefreshTokens) RevokeFamily(ctx context.Context, familyID string) error {
+	r.revokeWhere(func(t *models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *memoryRefreshTokens) RevokeAllForUser(ctx context.Context, userID string) error {
+	r.revokeWhere(func(t *models.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (r *memoryRefreshTokens) revokeWhere(match func(*models.RefreshToken) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+		}
+	}
+}
+
+type memoryAccessTokens memoryStore
+
+func (r *memoryAccessTokens) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	token.ID, token.CreatedAt, token.UpdatedAt = (*memoryStore)(r).nextID(), now, now
+	t := *token
+	r.accessTokens[t.TokenHash] = &t
+	return nil
+}
+
+func (r *memoryAccessTokens) ListForUser(ctx context.Context, userID string) ([]models.PersonalAccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []models.PersonalAccessToken
+	for _, token := range r.accessTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			tokens = append(tokens, *token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+func (r *memoryAccessTokens) FindByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.accessTokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+func (r *memoryAccessTokens) Revoke(ctx context.Context, userID, tokenID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.accessTokens {
+		if token.TokenID == tokenID && token.UserID == userID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryAccessTokens) RevokeAllForUser(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.accessTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryAccessTokens) Touch(ctx context.Context, id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.accessTokens {
+		if token.ID == id {
+			token.LastUsedAt = &at
+			return nil
+		}
+	}
+	return nil
+}
+
+type memoryEmailVerifications memoryStore
+
+func (r *memoryEmailVerifications) Create(ctx context.Context, v *models.EmailVerification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, existing := range r.verifications {
+		if existing.UserID == v.UserID && existing.UsedAt == nil {
+			existing.UsedAt = &now
+		}
+	}
+	v.ID, v.CreatedAt, v.UpdatedAt = (*memoryStore)(r).nextID(), now, now
+	stored := *v
+	r.verifications[stored.JTI] = &stored
+	return nil
+}
+
+func (r *memoryEmailVerifications) Redeem(ctx context.Context, jti, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.verifications[jti]
+	if !ok || v.UserID != userID || v.UsedAt != nil {
+		return ErrTokenUsed
+	}
+	now := time.Now()
+	v.UsedAt = &now
+	if user, ok := r.users[userID]; ok {
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+	}
+	return nil
+}
+
+type memoryPasswordResets memoryStore
+
+func (r *memoryPasswordResets) Create(ctx context.Context, reset *models.PasswordReset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, existing := range r.resets {
+		if existing.UserID == reset.UserID && existing.UsedAt == nil {
+			existing.UsedAt = &now
+		}
+	}
+	reset.ID, reset.CreatedAt, reset.UpdatedAt = (*memoryStore)(r).nextID(), now, now
+	stored := *reset
+	r.resets[stored.TokenHash] = &stored
+	return nil
+}
+
+func (r *memoryPasswordResets) FindValid(ctx context.Context, tokenHash string) (*models.PasswordReset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reset, ok := r.resets[tokenHash]
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	stored := *reset
+	return &stored, nil
+}
+
+func (r *memoryPasswordResets) Redeem(ctx context.Context, reset *models.PasswordReset, hash string, keepHistory int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.resets[reset.TokenHash]
+	if !ok || stored.UsedAt != nil {
+		return ErrTokenUsed
+	}
+	now := time.Now()
+	stored.UsedAt = &now
+	(*memoryStore)(r).setPassword(reset.UserID, hash, keepHistory)
+	return nil
+}
+
+type memoryTwoFactor memoryStore
+
+func (r *memoryTwoFactor) update(userID string, apply func(*models.User)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user, ok := r.users[userID]; ok {
+		apply(user)
+	}
+}
+
+func (r *memoryTwoFactor) SetSecret(ctx context.Context, userID, secret string) error {
+	r.update(userID, func(u *models.User) { u.TOTPSecret = secret })
+	return nil
+}
+
+func (r *memoryTwoFactor) Enable(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	r.update(userID, func(u *models.User) {
+		u.TOTPEnabled = true
+		u.TOTPLastStep = step
+		codes := make([]*models.RecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = &models.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		r.recoveryCodes[userID] = codes
+	})
+	return nil
+}
+
+func (r *memoryTwoFactor) Disable(ctx context.Context, userID string) error {
+	r.update(userID, func(u *models.User) {
+		u.TOTPEnabled = false
+		u.TOTPSecret = ""
+		u.TOTPLastStep = 0
+		delete(r.recoveryCodes, userID)
+	})
+	return nil
+}
+
+func (r *memoryTwoFactor) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	used := false
+	r.update(userID, func(u *models.User) {
+		if u.TOTPLastStep < step {
+			u.TOTPLastStep = step
+			used = true
+		}
+	})
+	return used, nil
+}
+
+func (r *memoryTwoFactor) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, code := range r.recoveryCodes[userID] {
+		if code.CodeHash == codeHash && code.UsedAt == nil {
+			now := time.Now()
+			code.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryRevocations memoryStore
+
+func (r *memoryRevocations) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.revokedTokens[jti]; !ok {
+		r.revokedTokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (r *memoryRevocations) RevokeUser(ctx context.Context, userID string, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokedUsers[userID] = before
+	return nil
+}
+
+func (r *memoryRevocations) Load(ctx context.Context, now time.Time) (map[string]time.Time, map[string]time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tokens := make(map[string]time.Time, len(r.revokedTokens))
+	for jti, expiresAt := range r.revokedTokens {
+		if expiresAt.Before(now) {
+			delete(r.revokedTokens, jti)
+			continue
+		}
+		tokens[jti] = expiresAt
+	}
+	users := make(map[string]time.Time, len(r.revokedUsers))
+	for userID, before := range r.revokedUsers {
+		users[userID] = before
+	}
+	return tokens, users, nil
+}
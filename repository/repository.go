@@ -0,0 +1,138 @@
+// Package repository hides how users, organisations and tokens are stored
+// from the HTTP handlers. Every repository has a gorm implementation for
+// production and an in-memory one for tests.
+//
+// Methods that have to change several records at once, such as redeeming a
+// reset token and setting the new password, are single operations here so
+// implementations can make them atomic.
+package repository
+
+import (
+	"context"
+	"errors"
+	"hng/models"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("record not found")
+	ErrDuplicateEmail = errors.New("email already exists")
+	// ErrTokenUsed is returned when a single-use token was redeemed by a
+	// concurrent request first.
+	ErrTokenUsed = errors.New("token already used")
+)
+
+type UserRepository interface {
+	FindByID(ctx context.Context, userID string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// Register stores user, whose password is already hashed, together
+	// with org as their default organisation, which they own. It sets
+	// user.PasswordChangedAt.
+	Register(ctx context.Context, user *models.User, org *models.Organisation) error
+	// SetPassword stores hash as the user's password, updates
+	// PasswordChangedAt and keeps the newest keepHistory hashes in their
+	// password history.
+	SetPassword(ctx context.Context, userID, hash string, keepHistory int) error
+	// ReplacePasswordHash swaps oldHash for newHash, a hash of the same
+	// password, in the user's password and history. PasswordChangedAt is
+	// left alone. It returns ErrNotFound if the password is no longer
+	// oldHash.
+	ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error
+	// RecentPasswordHashes returns up to n previous hashes, newest first.
+	RecentPasswordHashes(ctx context.Context, userID string, n int) ([]string, error)
+}
+
+type OrganisationRepository interface {
+	FindByOrgID(ctx context.Context, orgID string) (*models.Organisation, error)
+	// ListForUser returns the organisations userID is a member of.
+	ListForUser(ctx context.Context, userID string) ([]models.Organisation, error)
+	// Create stores org with owner as its owner.
+	Create(ctx context.Context, org *models.Organisation, owner *models.User) error
+	// FindMembership returns userID's membership of orgID.
+	FindMembership(ctx context.Context, orgID, userID string) (*models.Membership, error)
+	// AddMember stores m unless the user is already a member, in which case
+	// the existing membership is returned and nothing changes.
+	AddMember(ctx context.Context, m models.Membership) (existing *models.Membership, err error)
+	// ShareOrganisation reports whether both users belong to at least one
+	// common organisation.
+	ShareOrganisation(ctx context.Context, userID, otherUserID string) (bool, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkUsed claims an unused, unrevoked token. It returns false if the
+	// token was already used or revoked.
+	MarkUsed(ctx context.Context, id uint) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+}
+
+type PersonalAccessTokenRepository interface {
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	// ListForUser returns userID's unrevoked tokens, oldest first. Expired
+	// ones are included so the user can see they need replacing.
+	ListForUser(ctx context.Context, userID string) ([]models.PersonalAccessToken, error)
+	FindByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	// Revoke revokes userID's token with tokenID. It returns ErrNotFound if
+	// the user has no such unrevoked token.
+	Revoke(ctx context.Context, userID, tokenID string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+	// Touch records that the token was used at.
+	Touch(ctx context.Context, id uint, at time.Time) error
+}
+
+type EmailVerificationRepository interface {
+	// Create stores v and supersedes the user's earlier unused tokens.
+	Create(ctx context.Context, v *models.EmailVerification) error
+	// Redeem marks the token with jti used and the user's email verified.
+	// It returns ErrTokenUsed if the token is unknown, used or superseded.
+	Redeem(ctx context.Context, jti, userID string) error
+}
+
+type PasswordResetRepository interface {
+	// Create stores r and supersedes the user's earlier unused tokens.
+	Create(ctx context.Context, r *models.PasswordReset) error
+	// FindValid returns the unused, unexpired reset with tokenHash.
+	FindValid(ctx context.Context, tokenHash string) (*models.PasswordReset, error)
+	// Redeem marks the reset used and sets the user's password as
+	// UserRepository.SetPassword does. It returns ErrTokenUsed if the reset
+	// was redeemed first.
+	Redeem(ctx context.Context, reset *models.PasswordReset, hash string, keepHistory int) error
+}
+
+type TwoFactorRepository interface {
+	SetSecret(ctx context.Context, userID, secret string) error
+	// Enable turns TOTP on, records step as used and replaces the user's
+	// recovery codes with codeHashes.
+	Enable(ctx context.Context, userID string, step int64, codeHashes []string) error
+	// Disable turns TOTP off and removes the secret and recovery codes.
+	Disable(ctx context.Context, userID string) error
+	// UseStep records step as the latest accepted TOTP step. It returns
+	// false if that step or a later one was already used.
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	// UseRecoveryCode consumes the unused recovery code with codeHash.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+}
+
+// RevocationRepository persists revoked access tokens for
+// utils.RevocationStore.
+type RevocationRepository interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, userID string, before time.Time) error
+	// Load drops revocations of tokens that expired before now and returns
+	// the rest, keyed by JTI and by user ID.
+	Load(ctx context.Context, now time.Time) (tokens, users map[string]time.Time, err error)
+}
+
+// Repositories bundles one implementation of every repository.
+type Repositories struct {
+	Users              UserRepository
+	Organisations      OrganisationRepository
+	RefreshTokens      RefreshTokenRepository
+	AccessTokens       PersonalAccessTokenRepository
+	EmailVerifications EmailVerificationRepository
+	PasswordResets     PasswordResetRepository
+	TwoFactor          TwoFactorRepository
+	Revocations        RevocationRepository
+}
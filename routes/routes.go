@@ -1,72 +1,261 @@
 package routes
 
 import (
-	"hng/utils"
+	"hng/apperr"
+	"hng/auth"
 	"hng/controllers"
+	"hng/models"
+	"hng/ratelimit"
+	"hng/repository"
+	"hng/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func AuthRoutes(r *gin.Engine, db *gorm.DB) {
+// perMinute turns a configured per-group limit into a ratelimit.Limit.
+func perMinute(requests int) ratelimit.Limit {
+	return ratelimit.Limit{Requests: requests, Per: time.Minute}
+}
+
+// Setup registers every route group on r.
+func Setup(r *gin.Engine, h *controllers.Handler, limiter ratelimit.Backend) {
+	// Registered first so it renders errors from every route.
+	r.Use(apperr.Middleware())
+	r.NoRoute(func(c *gin.Context) { apperr.Abort(c, apperr.New(apperr.NotFound)) })
+
+	AuthRoutes(r, h, limiter)
+	UserRoutes(r, h, limiter)
+	OrganisationRoutes(r, h, limiter)
+	AdminRoutes(r, h, limiter)
+	WellKnownRoutes(r, h, limiter)
+	r.GET("/api/errors", controllers.ErrorCatalogue)
+}
+
+// Auth and well-known endpoints are anonymous so they are rate limited by
+// client address; the other groups are limited per authenticated user.
+func AuthRoutes(r *gin.Engine, h *controllers.Handler, limiter ratelimit.Backend) {
 	auth := r.Group("/auth")
-	auth.Use(DbMiddleware(db))
+	auth.Use(ratelimit.Middleware(limiter, "auth", perMinute(h.Config.RateLimit.Auth), ratelimit.ByIP))
 	{
-		auth.POST("/register", controllers.Register)
-		auth.POST("/login", controllers.Login)
+		auth.POST("/register", h.Register)
+		auth.POST("/login", h.Login)
+		auth.POST("/login/mfa", h.LoginMFA)
+		auth.POST("/verify-email", h.VerifyEmail)
+		auth.POST("/resend-verification", h.ResendVerification)
+		auth.POST("/forgot-password", h.ForgotPassword)
+		auth.POST("/reset-password", h.ResetPassword)
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/logout", authMiddleware(h.Revocations, h.Repos.AccessTokens), sessionMiddleware(), h.Logout)
+		auth.POST("/logout-all", authMiddleware(h.Revocations, h.Repos.AccessTokens), sessionMiddleware(), h.LogoutAll)
 	}
 }
 
-func UserRoutes(r *gin.Engine, db *gorm.DB) {
+func UserRoutes(r *gin.Engine, h *controllers.Handler, limiter ratelimit.Backend) {
 	user := r.Group("/api/users")
-	user.Use(DbMiddleware(db), authMiddleware())
+	user.Use(authMiddleware(h.Revocations, h.Repos.AccessTokens))
+	user.Use(ratelimit.Middleware(limiter, "users", perMinute(h.Config.RateLimit.Users), ratelimit.ByUser))
 	{
-		user.GET("/:id", controllers.GetUser)
+		user.GET("/:id", h.GetUser)
 	}
-}
 
-func OrganisationRoutes(r *gin.Engine, db *gorm.DB) {
-	po := r.Group("/api")
-	po.Use(DbMiddleware(db))
+	// Changing credentials needs a real login, so a leaked personal access
+	// token cannot be used to take over the account or mint more tokens.
+	me := user.Group("/me", sessionMiddleware())
 	{
-		po.POST("organisations/:orgId/users", controllers.AddUserToOrganisation)
+		me.POST("/password", h.ChangePassword)
+		me.POST("/2fa/setup", h.SetupTwoFactor)
+		me.POST("/2fa/confirm", h.ConfirmTwoFactor)
+		me.POST("/2fa/disable", h.DisableTwoFactor)
+		me.GET("/tokens", h.ListAccessTokens)
+		me.POST("/tokens", h.CreateAccessToken)
+		me.DELETE("/tokens/:tokenId", h.RevokeAccessToken)
 	}
+}
 
+func OrganisationRoutes(r *gin.Engine, h *controllers.Handler, limiter ratelimit.Backend) {
 	org := r.Group("/api/organisations")
-	
-	org.Use(DbMiddleware(db), authMiddleware())
+
+	org.Use(authMiddleware(h.Revocations, h.Repos.AccessTokens))
+	org.Use(ratelimit.Middleware(limiter, "organisations", perMinute(h.Config.RateLimit.Organisations), ratelimit.ByUser))
 	{
-		org.GET("/", controllers.GetOrganisations)
-		org.GET("/:orgId", controllers.GetOrganisation)
-		org.POST("/", controllers.CreateOrganisation)
-		
+		// Both spellings are served directly; clients have always used
+		// the trailing slash and a redirect would turn POSTs into 307s.
+		org.GET("/", h.GetOrganisations)
+		org.GET("", h.GetOrganisations)
+		org.GET("/:orgId", orgPermissionMiddleware(h.Repos.Organisations, models.PermViewOrganisation), h.GetOrganisation)
+		org.POST("/", h.CreateOrganisation)
+		org.POST("", h.CreateOrganisation)
+		org.POST("/:orgId/users", orgPermissionMiddleware(h.Repos.Organisations, models.PermAddMember), h.AddUserToOrganisation)
 	}
 }
 
-func DbMiddleware(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Set("db", db)
-		c.Next()
+func AdminRoutes(r *gin.Engine, h *controllers.Handler, limiter ratelimit.Backend) {
+	admin := r.Group("/api/admin")
+	admin.Use(authMiddleware(h.Revocations, h.Repos.AccessTokens))
+	admin.Use(ratelimit.Middleware(limiter, "admin", perMinute(h.Config.RateLimit.Admin), ratelimit.ByUser))
+	admin.Use(adminMiddleware(h.Repos.Users))
+	{
+		admin.POST("/users/:id/unlock", h.UnlockUser)
+		admin.GET("/metrics", h.Metrics)
 	}
 }
 
-func authMiddleware() gin.HandlerFunc {
+func WellKnownRoutes(r *gin.Engine, h *controllers.Handler, limiter ratelimit.Backend) {
+	wellKnown := r.Group("/.well-known")
+	wellKnown.Use(ratelimit.Middleware(limiter, "wellKnown", perMinute(h.Config.RateLimit.WellKnown), ratelimit.ByIP))
+	{
+		wellKnown.GET("/jwks.json", controllers.JWKS)
+	}
+}
+
+// authMiddleware authenticates the caller with either a JWT access token
+// from a login or a personal access token.
+func authMiddleware(revocations *utils.RevocationStore, accessTokens repository.PersonalAccessTokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
 		if token == "" {
-			c.JSON(401, gin.H{"status": "unauthorized", "message": "Missing authorization header"})
-			c.Abort()
+			apperr.Abort(c, apperr.New(apperr.AuthMissingToken))
+			return
+		}
+
+		if strings.HasPrefix(token, utils.AccessTokenPrefix) {
+			authenticateAccessToken(c, accessTokens, token)
 			return
 		}
 
 		claims, err := utils.ValidateToken(token)
 		if err != nil {
-			c.JSON(401, gin.H{"status": "unauthorized", "message": "Invalid token"})
-			c.Abort()
+			apperr.Abort(c, apperr.New(apperr.AuthInvalidToken))
+			return
+		}
+
+		revoked, err := revocations.IsRevoked(claims)
+		if err != nil {
+			apperr.Abort(c, apperr.Internal(err, "Could not verify token"))
+			return
+		}
+		if revoked {
+			apperr.Abort(c, apperr.New(apperr.AuthTokenRevoked))
+			return
+		}
+
+		auth.SetPrincipal(c, &auth.Principal{
+			UserID:    claims.Subject,
+			Email:     claims.Email,
+			Method:    auth.MethodSession,
+			TokenID:   claims.Id,
+			ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		})
+		c.Next()
+	}
+}
+
+// accessTokenTouchInterval is how stale a personal access token's
+// LastUsedAt may get, so busy scripts do not write on every request.
+const accessTokenTouchInterval = time.Minute
+
+// authenticateAccessToken authenticates the caller with a personal access
+// token. Safe requests need the read scope and the rest the write scope.
+func authenticateAccessToken(c *gin.Context, accessTokens repository.PersonalAccessTokenRepository, token string) {
+	ctx := c.Request.Context()
+	pat, err := accessTokens.FindByHash(ctx, utils.HashToken(token))
+	if err == repository.ErrNotFound {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidToken))
+		return
+	}
+	if err != nil {
+		apperr.Abort(c, apperr.Internal(err, "Could not verify token"))
+		return
+	}
+
+	now := time.Now()
+	if pat.RevokedAt != nil {
+		apperr.Abort(c, apperr.New(apperr.AuthTokenRevoked))
+		return
+	}
+	if pat.Expired(now) {
+		apperr.Abort(c, apperr.New(apperr.AuthInvalidToken))
+		return
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) >= accessTokenTouchInterval {
+		if err := accessTokens.Touch(ctx, pat.ID, now); err != nil {
+			log.Printf("Error recording access token use: %v", err)
+		}
+	}
+
+	principal := &auth.Principal{
+		UserID:  pat.UserID,
+		Method:  auth.MethodAccessToken,
+		TokenID: pat.TokenID,
+		Scopes:  pat.ScopeList(),
+	}
+	if pat.ExpiresAt != nil {
+		principal.ExpiresAt = *pat.ExpiresAt
+	}
+
+	scope := models.ScopeWrite
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		scope = models.ScopeRead
+	}
+	if !principal.Allows(scope) {
+		apperr.Abort(c, apperr.New(apperr.AuthInsufficientScope))
+		return
+	}
+
+	auth.SetPrincipal(c, principal)
+	c.Next()
+}
+
+// sessionMiddleware aborts unless the caller authenticated with a login
+// rather than a personal access token.
+func sessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.MustPrincipal(c).Method != auth.MethodSession {
+			apperr.Abort(c, apperr.New(apperr.AuthSessionRequired))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// adminMiddleware aborts unless the authenticated caller is an admin.
+func adminMiddleware(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := users.FindByID(c.Request.Context(), auth.MustPrincipal(c).UserID)
+		if err != nil || !user.IsAdmin {
+			apperr.Abort(c, apperr.New(apperr.Forbidden))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// orgPermissionMiddleware loads the caller's membership in the :orgId
+// organisation and aborts unless its role grants perm. Organisations the
+// caller is not a member of are reported as missing so their existence is
+// not leaked.
+func orgPermissionMiddleware(organisations repository.OrganisationRepository, perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		membership, err := organisations.FindMembership(c.Request.Context(), c.Param("orgId"), auth.MustPrincipal(c).UserID)
+		if err != nil {
+			apperr.Abort(c, apperr.New(apperr.OrgNotFound))
+			return
+		}
+
+		if !membership.Can(perm) {
+			apperr.Abort(c, apperr.New(apperr.Forbidden))
 			return
 		}
 
-		c.Set("userId", claims.UserID)
+		c.Set("membership", *membership)
 		c.Next()
 	}
 }
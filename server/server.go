@@ -0,0 +1,70 @@
+// Package server runs the HTTP server and drains it on shutdown.
+package server
+
+import (
+	"context"
+	"errors"
+	"hng/config"
+	"log"
+	"net"
+	"net/http"
+)
+
+// New returns an http.Server for handler with cfg's limits applied.
+func New(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// Run listens on srv.Addr and serves until ctx is done, then shuts down
+// gracefully. See Serve.
+func Run(ctx context.Context, srv *http.Server, cfg config.ServerConfig) error {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, srv, ln, cfg)
+}
+
+// Serve serves on ln, over TLS when cfg has a certificate, until ctx is
+// done. It then stops accepting connections and waits up to
+// cfg.ShutdownTimeout for in-flight requests before closing the rest.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, cfg config.ServerConfig) error {
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLSCertFile != "" {
+			log.Printf("Listening on %s (TLS)", ln.Addr())
+			errc <- srv.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Printf("Listening on %s", ln.Addr())
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		// The server stopped on its own, e.g. a bad certificate.
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for requests to finish", cfg.ShutdownTimeout.Duration)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
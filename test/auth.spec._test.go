@@ -2,93 +2,161 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
-	"hng/controllers"
-	"hng/models"
+	"errors"
+	"hng/config"
+	"hng/repository"
 	"hng/utils"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"strconv"
 	"testing"
 
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"github.com/stretchr/testify/require"
 )
 
-// SetupRouter initializes a Gin router with necessary middleware and routes
-func setupRouter() *gin.Engine {
-
-	dbUser := os.Getenv("POSTGRES_USER")
-	dbPassword := os.Getenv("POSTGRES_PASSWORD")
-	dbName := os.Getenv("POSTGRES_DB")
-	dbHost := os.Getenv("POSTGRES_HOST")
-	dbPort := os.Getenv("POSTGRES_PORT")
+func TestGenerateToken(t *testing.T) {
+	email := "test@example.com"
+	tokenString, err := utils.GenerateToken(utils.GenerateUUID(), email)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokenString)
+}
 
-	// Form the connection string
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		dbHost, dbUser, dbPassword, dbName, dbPort)
+func TestValidateToken(t *testing.T) {
+	userID := utils.GenerateUUID()
+	email := "test@example.com"
+	tokenString, _ := utils.GenerateToken(userID, email)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		panic("Failed to connect to database")
-	}
-	db.AutoMigrate(&models.User{}, &models.Organisation{})
+	claims, err := utils.ValidateToken(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.Subject)
+	assert.Equal(t, email, claims.Email)
+	assert.Equal(t, "hng", claims.Issuer)
+	assert.Equal(t, "hng-api", claims.Audience)
+	assert.NotEmpty(t, claims.Id)
+	assert.NotZero(t, claims.IssuedAt)
+	assert.Equal(t, claims.IssuedAt, claims.NotBefore)
+}
 
-	r := gin.Default()
-	r.Use(func(c *gin.Context) {
-		c.Set("db", db)
-	})
+// signTestClaims signs claims with the active key, bypassing GenerateToken.
+func signTestClaims(t *testing.T, claims *utils.Claims) string {
+	key, err := utils.Keys().SigningKey()
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Private)
+	require.NoError(t, err)
+	return signed
+}
 
-	authGroup := r.Group("/auth")
-	{
-		authGroup.POST("/register", controllers.Register)
-		authGroup.POST("/login", controllers.Login)
+func TestValidateTokenChecksStandardClaims(t *testing.T) {
+	now := time.Now()
+	valid := func() *utils.Claims {
+		return &utils.Claims{StandardClaims: jwt.StandardClaims{
+			Subject:   utils.GenerateUUID(),
+			Issuer:    "hng",
+			Audience:  "hng-api",
+			Id:        utils.GenerateUUID(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(time.Minute).Unix(),
+		}}
 	}
 
-	apiGroup := r.Group("/api")
-	{
-		apiGroup.GET("/users/:id", controllers.GetUser)
-		apiGroup.GET("/organisations", controllers.GetOrganisations)
-		apiGroup.GET("/organisations/:orgId", controllers.GetOrganisation)
-		apiGroup.POST("/organisations", controllers.CreateOrganisation)
-		apiGroup.POST("/organisations/:orgId/users", controllers.AddUserToOrganisation)
+	tests := []struct {
+		name   string
+		change func(c *utils.Claims)
+		valid  bool
+	}{
+		{"valid", func(c *utils.Claims) {}, true},
+		{"other issuer", func(c *utils.Claims) { c.Issuer = "someone-else" }, false},
+		{"other audience", func(c *utils.Claims) { c.Audience = "another-api" }, false},
+		{"no subject", func(c *utils.Claims) { c.Subject = "" }, false},
+		{"no id", func(c *utils.Claims) { c.Id = "" }, false},
+		{"no expiry", func(c *utils.Claims) { c.ExpiresAt = 0 }, false},
+		{"expired within skew", func(c *utils.Claims) { c.ExpiresAt = now.Add(-10 * time.Second).Unix() }, true},
+		{"expired beyond skew", func(c *utils.Claims) { c.ExpiresAt = now.Add(-time.Minute).Unix() }, false},
+		{"not before within skew", func(c *utils.Claims) { c.NotBefore = now.Add(10 * time.Second).Unix() }, true},
+		{"not before beyond skew", func(c *utils.Claims) { c.NotBefore = now.Add(time.Minute).Unix() }, false},
+		{"issued in the future", func(c *utils.Claims) { c.IssuedAt = now.Add(time.Minute).Unix() }, false},
 	}
 
-	return r
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := valid()
+			tc.change(claims)
+			_, err := utils.ValidateToken(signTestClaims(t, claims))
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
 }
 
-func TestGenerateToken(t *testing.T) {
-	email := "test@example.com"
-	tokenString, err := utils.GenerateToken(email)
-	assert.NoError(t, err)
-	assert.NotEmpty(t, tokenString)
+func TestActionTokensAreNotAccessTokens(t *testing.T) {
+	for _, purpose := range []string{utils.PurposeMFA, utils.PurposeVerifyEmail} {
+		tokenString, claims, err := utils.GenerateActionToken(purpose, utils.GenerateUUID(), "test@example.com", time.Minute)
+		require.NoError(t, err)
+		// Services that check only the audience must not take it for an
+		// access token either.
+		assert.Equal(t, "hng-api:"+purpose, claims.Audience)
+
+		_, err = utils.ValidateToken(tokenString)
+		assert.Error(t, err, purpose)
+		_, err = utils.ValidateActionToken(tokenString, purpose)
+		assert.NoError(t, err, purpose)
+	}
+
+	// An access token relabelled with a purpose keeps the access audience.
+	claims := &utils.Claims{Purpose: utils.PurposeMFA, StandardClaims: jwt.StandardClaims{
+		Subject:   utils.GenerateUUID(),
+		Issuer:    "hng",
+		Audience:  "hng-api",
+		Id:        utils.GenerateUUID(),
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}}
+	_, err := utils.ValidateActionToken(signTestClaims(t, claims), utils.PurposeMFA)
+	assert.Error(t, err)
 }
 
-func TestValidateToken(t *testing.T) {
-	email := "test@example.com"
-	tokenString, _ := utils.GenerateToken(email)
+func TestValidateTokenUsesConfiguredIssuer(t *testing.T) {
+	cfg := config.Default().JWT
+	cfg.Issuer = "https://auth.example.com"
+	cfg.Audience = "https://api.example.com"
+	utils.ConfigureTokens(cfg)
+	defer utils.ConfigureTokens(config.Default().JWT)
 
+	tokenString, err := utils.GenerateToken(utils.GenerateUUID(), "test@example.com")
+	require.NoError(t, err)
 	claims, err := utils.ValidateToken(tokenString)
-	assert.NoError(t, err)
-	assert.Equal(t, email, claims.Email)
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com", claims.Issuer)
+	assert.Equal(t, "https://api.example.com", claims.Audience)
+
+	// Tokens minted for the old audience are no longer accepted.
+	utils.ConfigureTokens(config.Default().JWT)
+	_, err = utils.ValidateToken(tokenString)
+	assert.Error(t, err)
 }
 
 func TestTokenExpiry(t *testing.T) {
 	email := "test@example.com"
-	tokenString, _ := utils.GenerateToken(email)
+	tokenString, _ := utils.GenerateToken(utils.GenerateUUID(), email)
 
 	claims, _ := utils.ValidateToken(tokenString)
 
-	// Ensure token expires in 24 hours
+	// Ensure token expires after the access token lifetime
 	expirationTime := time.Unix(claims.ExpiresAt, 0)
-	expectedExpirationTime := time.Now().Add(24 * time.Hour)
+	expectedExpirationTime := time.Now().Add(utils.AccessTokenTTL)
 	assert.WithinDuration(t, expectedExpirationTime, expirationTime, 5*time.Second)
 }
 
@@ -118,13 +186,16 @@ func TestRegisterUserSuccess(t *testing.T) {
 	assert.Equal(t, "success", response["status"])
 	assert.Equal(t, "Registration successful", response["message"])
 
+	// No tokens are issued until the email address is verified
 	data := response["data"].(map[string]interface{})
-	assert.NotNil(t, data["accessToken"])
+	assert.Nil(t, data["accessToken"])
 	user := data["user"].(map[string]interface{})
 	assert.Equal(t, "John", user["firstName"])
 	assert.Equal(t, "Doe", user["lastName"])
 	assert.Equal(t, "john.doe@example.com", user["email"])
 	assert.Equal(t, "1234567890", user["phone"])
+	assert.Equal(t, false, user["emailVerified"])
+	assert.NotEmpty(t, verificationToken(t, "john.doe@example.com"))
 }
 
 func TestLoginUserSuccess(t *testing.T) {
@@ -144,6 +215,10 @@ func TestLoginUserSuccess(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
+	// Verify the email address
+	w = verifyEmail(router, verificationToken(t, "john.doe@example.com"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
 	// Then login with the registered user's credentials
 	loginInput := map[string]string{
 		"email":    "john.doe@example.com",
@@ -190,7 +265,8 @@ func TestLoginUserFailure(t *testing.T) {
 
 	var response map[string]interface{}
 	json.Unmarshal(w.Body.Bytes(), &response)
-	assert.Equal(t, "Bad request", response["status"])
+	assert.Equal(t, "error", response["status"])
+	assert.Equal(t, "AUTH_INVALID_CREDENTIALS", response["code"])
 	assert.Equal(t, "Authentication failed", response["message"])
 	assert.Equal(t, float64(http.StatusUnauthorized), response["statusCode"].(float64))
 }
@@ -276,6 +352,8 @@ func TestRegisterUserDuplicateEmail(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Code)
 
 	// Second registration with the same email
+	req, _ = http.NewRequest("POST", "/auth/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -307,9 +385,7 @@ func TestAccessOrganisationData(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	var response1 map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &response1)
-	token1 := response1["data"].(map[string]interface{})["accessToken"].(string)
+	token1 := verifyAndLogin(t, router, "john.doe@example.com", "password123")["accessToken"].(string)
 
 	// Register second user
 	user2 := map[string]string{
@@ -325,9 +401,7 @@ func TestAccessOrganisationData(t *testing.T) {
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	var response2 map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &response2)
-	token2 := response2["data"].(map[string]interface{})["accessToken"].(string)
+	token2 := verifyAndLogin(t, router, "jane.doe@example.com", "password123")["accessToken"].(string)
 
 	// Create an organisation with first user
 	org := map[string]string{"name": "John's Organisation"}
@@ -340,18 +414,213 @@ func TestAccessOrganisationData(t *testing.T) {
 
 	var orgResponse map[string]interface{}
 	json.Unmarshal(w.Body.Bytes(), &orgResponse)
-	orgID := orgResponse["data"].(map[string]interface{})["id"].(float64)
+	orgID := orgResponse["data"].(map[string]interface{})["orgId"].(string)
 
 	// Try to access the organisation with second user
-	req, _ = http.NewRequest("GET", "/api/organisations/"+strconv.Itoa(int(orgID)), nil)
+	req, _ = http.NewRequest("GET", "/api/organisations/"+orgID, nil)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token2)
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusForbidden, w.Code)
+	// Organisations the user cannot see are indistinguishable from missing ones
+	assert.Equal(t, http.StatusNotFound, w.Code)
 	var accessResponse map[string]interface{}
 	json.Unmarshal(w.Body.Bytes(), &accessResponse)
-	assert.Equal(t, "forbidden", accessResponse["status"])
-	assert.Equal(t, "You do not have access to this organisation", accessResponse["message"])
+	assert.Equal(t, "Organisation not found", accessResponse["message"])
+}
+
+func refreshTokens(router *gin.Engine, refreshToken string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"refreshToken": refreshToken})
+	req, _ := http.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	router := setupRouter()
+
+	first := registerTestUser(t, router)["refreshToken"].(string)
+
+	// Using the refresh token rotates it
+	w := refreshTokens(router, first)
+	var response map[string]interface{}
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	data := response["data"].(map[string]interface{})
+	assert.NotEmpty(t, data["accessToken"])
+	second := data["refreshToken"].(string)
+	assert.NotEqual(t, first, second)
+
+	// Replaying the old token is detected and revokes the whole family
+	w = refreshTokens(router, first)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Refresh token reuse detected", response["message"])
+
+	w = refreshTokens(router, second)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// failingRevokeFamily is a refresh token repository whose RevokeFamily
+// always fails.
+type failingRevokeFamily struct {
+	repository.RefreshTokenRepository
+}
+
+func (failingRevokeFamily) RevokeFamily(ctx context.Context, familyID string) error {
+	return errors.New("database unavailable")
+}
+
+func TestRefreshTokenReuseFailsWhenFamilyCannotBeRevoked(t *testing.T) {
+	router := setupRouter()
+
+	first := registerTestUser(t, router)["refreshToken"].(string)
+	w := refreshTokens(router, first)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The client must not be told the family was revoked when it was not.
+	testRepos.RefreshTokens = failingRevokeFamily{testRepos.RefreshTokens}
+	w = refreshTokens(router, first)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestRefreshTokenInvalid(t *testing.T) {
+	router := setupRouter()
+
+	w := refreshTokens(router, "not-a-token")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// registerTestUser registers a user with a unique email, verifies the
+// address and returns the login response data.
+func registerTestUser(t *testing.T, router *gin.Engine) map[string]interface{} {
+	email := "user-" + utils.GenerateUUID() + "@example.com"
+	input := map[string]string{
+		"firstName": "Test",
+		"lastName":  "User",
+		"email":     email,
+		"password":  "password123",
+	}
+	w := postWithToken(router, "/auth/register", "", input)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	return verifyAndLogin(t, router, email, "password123")
+}
+
+func verifyAndLogin(t *testing.T, router *gin.Engine, email, password string) map[string]interface{} {
+	w := verifyEmail(router, verificationToken(t, email))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": password})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response["data"].(map[string]interface{})
+}
+
+func postWithToken(router *gin.Engine, path, token string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	accessToken := data["accessToken"].(string)
+	refreshToken := data["refreshToken"].(string)
+
+	w := postWithToken(router, "/auth/logout", accessToken, map[string]string{"refreshToken": refreshToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The access token is now on the revocation list
+	w = postWithToken(router, "/auth/logout", accessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The session's refresh token can no longer be used
+	w = refreshTokens(router, refreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	user := data["user"].(map[string]interface{})
+
+	loginInput := map[string]string{"email": user["email"].(string), "password": "password123"}
+	body, _ := json.Marshal(loginInput)
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	otherAccessToken := response["data"].(map[string]interface{})["accessToken"].(string)
+	otherRefreshToken := response["data"].(map[string]interface{})["refreshToken"].(string)
+
+	w = postWithToken(router, "/auth/logout-all", data["accessToken"].(string), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = refreshTokens(router, data["refreshToken"].(string))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = refreshTokens(router, otherRefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = getWithToken(router, "/api/organisations", otherAccessToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Logging in straight away, even within the same second, works.
+	w = postWithToken(router, "/auth/login", "", loginInput)
+	require.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	w = getWithToken(router, "/api/organisations", response["data"].(map[string]interface{})["accessToken"].(string))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSessionRevocationIsPreciseToTheMicrosecond(t *testing.T) {
+	store := utils.NewRevocationStore(repository.NewMemory().Revocations)
+	cutoff := time.Unix(1700000000, int64(500*time.Millisecond))
+	require.NoError(t, store.RevokeUser("user", cutoff))
+
+	issuedAt := func(at time.Time, withMicros bool) *utils.Claims {
+		claims := &utils.Claims{StandardClaims: jwt.StandardClaims{Subject: "user", Id: utils.GenerateUUID(), IssuedAt: at.Unix()}}
+		if withMicros {
+			claims.IssuedAtMicros = at.UnixMicro()
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		claims  *utils.Claims
+		revoked bool
+	}{
+		{"earlier in the same second", issuedAt(cutoff.Add(-time.Microsecond), true), true},
+		{"at the cutoff", issuedAt(cutoff, true), false},
+		{"later in the same second", issuedAt(cutoff.Add(time.Microsecond), true), false},
+		{"previous second without micros", issuedAt(cutoff.Add(-time.Second), false), true},
+		{"same second without micros", issuedAt(cutoff, false), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			revoked, err := store.IsRevoked(tc.claims)
+			require.NoError(t, err)
+			assert.Equal(t, tc.revoked, revoked)
+		})
+	}
+}
+
+func TestLogoutRequiresToken(t *testing.T) {
+	router := setupRouter()
+
+	w := postWithToken(router, "/auth/logout", "invalid", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
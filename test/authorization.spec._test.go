@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"bytes"
+	"hng/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publicRoutes are the only routes that may be called without an access
+// token. Any route added to the router is otherwise expected to need one.
+var publicRoutes = map[string]bool{
+	"POST /auth/register":            true,
+	"POST /auth/login":               true,
+	"POST /auth/login/mfa":           true,
+	"POST /auth/verify-email":        true,
+	"POST /auth/resend-verification": true,
+	"POST /auth/forgot-password":     true,
+	"POST /auth/reset-password":      true,
+	"POST /auth/refresh":             true,
+	"GET /.well-known/jwks.json":     true,
+	"GET /api/errors":                true,
+}
+
+func requestRoute(router *gin.Engine, method, path, authorization string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// routePath fills in path parameters with an ID that matches nothing.
+func routePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = utils.GenerateUUID()
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestEveryRouteRequiresAuthentication(t *testing.T) {
+	router := setupRouter()
+	user := registerTestUser(t, router)
+
+	// A token signed with someone else's key.
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, utils.Claims{
+		StandardClaims: jwt.StandardClaims{Subject: userIDOf(user), ExpiresAt: time.Now().Add(time.Hour).Unix()},
+	}).SignedString([]byte("not-the-signing-key-not-the-signing-key"))
+	require.NoError(t, err)
+
+	revoked := registerTestUser(t, router)["accessToken"].(string)
+	w := postWithToken(router, "/auth/logout", revoked, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	credentials := map[string]string{
+		"missing":    "",
+		"malformed":  "Bearer not-a-jwt",
+		"not bearer": "Basic " + user["accessToken"].(string),
+		"forged":     "Bearer " + forged,
+		"revoked":    "Bearer " + revoked,
+	}
+
+	for _, route := range router.Routes() {
+		if publicRoutes[route.Method+" "+route.Path] {
+			continue
+		}
+		for name, authorization := range credentials {
+			t.Run(route.Method+" "+route.Path+" "+name, func(t *testing.T) {
+				w := requestRoute(router, route.Method, routePath(route.Path), authorization)
+				assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+			})
+		}
+	}
+}
+
+func TestRoutesRejectUnauthorizedUsers(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	member := registerTestUser(t, router)
+	outsider := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+	w := postWithToken(router, "/api/organisations/"+orgID+"/users", owner["accessToken"].(string), map[string]string{"userId": userIDOf(member)})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		caller map[string]interface{}
+		status int
+	}{
+		{"non-admin unlocks an account", "POST", "/api/admin/users/" + userIDOf(outsider) + "/unlock", member, http.StatusForbidden},
+		{"outsider views an organisation", "GET", "/api/organisations/" + orgID, outsider, http.StatusNotFound},
+		{"outsider adds a member", "POST", "/api/organisations/" + orgID + "/users", outsider, http.StatusNotFound},
+		{"member adds a member", "POST", "/api/organisations/" + orgID + "/users", member, http.StatusForbidden},
+		{"stranger views a user", "GET", "/api/users/" + userIDOf(owner), outsider, http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := requestRoute(router, tc.method, tc.path, "Bearer "+tc.caller["accessToken"].(string))
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+}
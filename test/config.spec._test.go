@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"hng/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFileWithEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte(`
+server:
+  addr: ":8080"
+  readTimeout: 3s
+database:
+  host: db.internal
+  sslMode: require
+rateLimit:
+  auth: 5
+`), 0600))
+	t.Setenv("CONFIG_FILE", yamlFile)
+	// The environment wins over the file, so clear POSTGRES_HOST for the
+	// file's value to show. t.Setenv restores it afterwards.
+	t.Setenv("POSTGRES_HOST", "")
+	os.Unsetenv("POSTGRES_HOST")
+	t.Setenv("RATE_LIMIT_AUTH", "7")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 172.16.0.0/12")
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, 3*time.Second, cfg.Server.ReadTimeout.Duration)
+	assert.Equal(t, "db.internal", cfg.Database.Host)
+	assert.Contains(t, cfg.Database.DSN(), "sslmode='require'")
+	assert.Equal(t, 7, cfg.RateLimit.Auth)
+	assert.Equal(t, 120, cfg.RateLimit.Users)
+	assert.Equal(t, []string{"10.0.0.1", "172.16.0.0/12"}, cfg.Server.TrustedProxies)
+
+	tomlFile := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(tomlFile, []byte(`
+[server]
+shutdownTimeout = "1m"
+
+[database]
+host = "toml.internal"
+port = 6543
+
+[jwt]
+keyFiles = ["a.pem", "b.pem"]
+issuer = "https://auth.example.com"
+clockSkew = "5s"
+`), 0600))
+	t.Setenv("CONFIG_FILE", tomlFile)
+
+	cfg, err = config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, cfg.Server.ShutdownTimeout.Duration)
+	assert.Equal(t, "toml.internal", cfg.Database.Host)
+	assert.Equal(t, 6543, cfg.Database.Port)
+	assert.Equal(t, []string{"a.pem", "b.pem"}, cfg.JWT.KeyFiles)
+	assert.Equal(t, "https://auth.example.com", cfg.JWT.Issuer)
+	assert.Equal(t, "hng-api", cfg.JWT.Audience)
+	assert.Equal(t, 5*time.Second, cfg.JWT.ClockSkew.Duration)
+}
+
+func TestDatabaseDSNQuotesValues(t *testing.T) {
+	db := config.Default().Database
+	db.Host = "db.internal"
+	db.User = "app"
+	db.Name = "users"
+	db.Password = `gen er'at\ed`
+
+	parsed, err := pgx.ParseConfig(db.DSN())
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal", parsed.Host)
+	assert.Equal(t, "app", parsed.User)
+	assert.Equal(t, "users", parsed.Database)
+	assert.Equal(t, `gen er'at\ed`, parsed.Password)
+}
+
+func TestConfigValidationListsEveryProblem(t *testing.T) {
+	t.Setenv("POSTGRES_HOST", "")
+	t.Setenv("JWT_SECRET", "too-short")
+	t.Setenv("MAILER", "smtp")
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("LOCKOUT_STORE", "redis")
+	t.Setenv("TLS_CERT_FILE", "cert.pem")
+	t.Setenv("JWT_AUDIENCE", "")
+	t.Setenv("JWT_CLOCK_SKEW", "-1s")
+	t.Setenv("PASSWORD_ALGORITHM", "scrypt")
+	t.Setenv("PASSWORD_MAX_LENGTH", "4")
+	t.Setenv("PASSWORD_HASH_QUEUE_DEPTH", "-1")
+	t.Setenv("TRUSTED_PROXIES", "proxy.internal")
+
+	_, err := config.Load()
+	require.Error(t, err)
+	for _, want := range []string{"POSTGRES_HOST", "JWT_SECRET", "SMTP_HOST", "LOCKOUT_STORE", "TLS_KEY_FILE", "JWT_AUDIENCE", "JWT_CLOCK_SKEW", "PASSWORD_ALGORITHM", "PASSWORD_MAX_LENGTH", "PASSWORD_HASH_QUEUE_DEPTH", "TRUSTED_PROXIES"} {
+		assert.Contains(t, err.Error(), want)
+	}
+
+	// Problems are listed in the same order on every run.
+	t.Setenv("POSTGRES_USER", "")
+	t.Setenv("POSTGRES_DB", "")
+	t.Setenv("HTTP_READ_TIMEOUT", "-1s")
+	t.Setenv("HTTP_SHUTDOWN_TIMEOUT", "-1s")
+	t.Setenv("RATE_LIMIT_AUTH", "0")
+	t.Setenv("RATE_LIMIT_ORGANISATIONS", "0")
+	_, err = config.Load()
+	require.Error(t, err)
+	for i := 0; i < 20; i++ {
+		_, again := config.Load()
+		require.Equal(t, err.Error(), again.Error())
+	}
+	message := err.Error()
+	for _, pair := range [][2]string{
+		{"HTTP_READ_TIMEOUT", "HTTP_SHUTDOWN_TIMEOUT"},
+		{"POSTGRES_HOST", "POSTGRES_USER"},
+		{"POSTGRES_USER", "POSTGRES_DB"},
+		{"RATE_LIMIT_AUTH", "RATE_LIMIT_ORGANISATIONS"},
+	} {
+		assert.Less(t, strings.Index(message, pair[0]), strings.Index(message, pair[1]), pair)
+	}
+
+	t.Setenv("HTTP_IDLE_TIMEOUT", "soon")
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "HTTP_IDLE_TIMEOUT")
+
+	t.Setenv("POSTGRES_PORT", "not-a-port")
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "POSTGRES_PORT")
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"hng/config"
+	"hng/credentials"
+	"hng/models"
+	"hng/repository"
+	"hng/utils"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Stored passwords used to be hashed again on every save of a user, which
+// locked the user out after any update. These tests pin the fix.
+
+func TestLoginAfterMembershipChange(t *testing.T) {
+	// The in-memory repositories keep the login-after-update path covered
+	// when no Postgres is configured; the gorm run catches model hooks.
+	routers := map[string]func(t *testing.T) *gin.Engine{
+		"memory": func(t *testing.T) *gin.Engine { return setupRouter() },
+		"gorm":   func(t *testing.T) *gin.Engine { return setupRouterOn(repository.NewGorm(postgresDB(t))) },
+	}
+	for name, setup := range routers {
+		t.Run(name, func(t *testing.T) {
+			router := setup(t)
+			owner := registerTestUser(t, router)
+			member := registerTestUser(t, router)
+			ownerToken := owner["accessToken"].(string)
+
+			orgID := createTestOrganisation(t, router, ownerToken)
+			w := postWithToken(router, "/api/organisations/"+orgID+"/users", ownerToken, map[string]string{"userId": userIDOf(member)})
+			require.Equal(t, http.StatusOK, w.Code)
+
+			w = postWithToken(router, "/auth/login", "", map[string]string{"email": emailOf(member), "password": "password123"})
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestSavingUserRunsNoPasswordHooks(t *testing.T) {
+	db := dryRunDB(t)
+	hash, err := (&credentials.Bcrypt{Cost: bcrypt.MinCost}).Hash("password123")
+	require.NoError(t, err)
+	user := &models.User{UserID: utils.GenerateUUID(), FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Password: hash}
+
+	saves := map[string]func() error{
+		"create": func() error { return db.Create(user).Error },
+		"save":   func() error { return db.Save(user).Error },
+		"update": func() error {
+			return db.Model(user).Where("user_id = ?", user.UserID).Updates(models.User{FirstName: "Janet"}).Error
+		},
+	}
+	for name, save := range saves {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, save())
+			assert.Equal(t, hash, user.Password)
+		})
+	}
+}
+
+func TestPasswordChangeStampsPasswordChangedAt(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	ctx := context.Background()
+
+	user, err := testRepos.Users.FindByID(ctx, userIDOf(data))
+	require.NoError(t, err)
+	require.NotNil(t, user.PasswordChangedAt)
+	registeredAt := *user.PasswordChangedAt
+	registeredHash := user.Password
+
+	w := postWithToken(router, "/api/users/me/password", data["accessToken"].(string), map[string]string{
+		"currentPassword": "password123",
+		"newPassword":     "newpassword456",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	user, err = testRepos.Users.FindByID(ctx, userIDOf(data))
+	require.NoError(t, err)
+	assert.NotEqual(t, registeredHash, user.Password)
+	assert.False(t, user.PasswordChangedAt.Before(registeredAt))
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": emailOf(data), "password": "newpassword456"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSavingUserKeepsPasswordHash(t *testing.T) {
+	db := postgresDB(t)
+	repos := repository.NewGorm(db)
+	hasher, err := credentials.NewHasher(config.Default().Password)
+	require.NoError(t, err)
+	pool := credentials.NewPool(1, 0)
+	t.Cleanup(pool.Close)
+	service := credentials.NewService(repos, hasher, pool)
+	ctx := context.Background()
+
+	user := &models.User{
+		UserID:    utils.GenerateUUID(),
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     utils.GenerateUUID() + "@example.com",
+		Password:  "password123",
+	}
+	org := &models.Organisation{OrgID: utils.GenerateUUID(), Name: "Jane's Organisation"}
+	require.NoError(t, service.Register(ctx, user, org))
+
+	stored, err := repos.Users.FindByID(ctx, user.UserID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.PasswordChangedAt)
+
+	// A full save of the user, as a profile update would do.
+	stored.FirstName = "Janet"
+	require.NoError(t, db.Save(stored).Error)
+
+	reloaded, err := repos.Users.FindByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, "Janet", reloaded.FirstName)
+	assert.Equal(t, stored.Password, reloaded.Password)
+	ok, err := service.Verify(ctx, reloaded, "password123")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := map[string]credentials.PasswordHasher{
+		"argon2id": &credentials.Argon2id{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		"bcrypt":   &credentials.Bcrypt{Cost: bcrypt.MinCost},
+	}
+	formats := map[string]*regexp.Regexp{
+		"argon2id": regexp.MustCompile(`^\$argon2id\$v=19\$m=8192,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`),
+		"bcrypt":   regexp.MustCompile(`^\$bcrypt\$r=4\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{31}$`),
+	}
+
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hash, err := hasher.Hash("password123")
+			require.NoError(t, err)
+			assert.Regexp(t, formats[name], hash)
+
+			again, err := hasher.Hash("password123")
+			require.NoError(t, err)
+			assert.NotEqual(t, hash, again, "hashes must be salted")
+
+			ok, err := hasher.Verify(hash, "password123")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = hasher.Verify(hash, "password124")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, hasher.NeedsRehash(hash))
+		})
+	}
+
+	// Each hasher only understands its own format.
+	argonHash, _ := hashers["argon2id"].Hash("password123")
+	_, err := hashers["bcrypt"].Verify(argonHash, "password123")
+	assert.ErrorIs(t, err, credentials.ErrUnknownHash)
+	_, err = hashers["argon2id"].Verify("$argon2id$v=19$m=8192,t=0,p=1$c2FsdA$a2V5", "password123")
+	assert.ErrorIs(t, err, credentials.ErrUnknownHash)
+}
+
+func TestPasswordHashersNeedRehash(t *testing.T) {
+	weakArgon := &credentials.Argon2id{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	strongArgon := &credentials.Argon2id{Memory: 16 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	weakBcrypt := &credentials.Bcrypt{Cost: bcrypt.MinCost}
+	strongBcrypt := &credentials.Bcrypt{Cost: bcrypt.MinCost + 1}
+
+	weakArgonHash, _ := weakArgon.Hash("password123")
+	strongArgonHash, _ := strongArgon.Hash("password123")
+	weakBcryptHash, _ := weakBcrypt.Hash("password123")
+
+	assert.True(t, strongArgon.NeedsRehash(weakArgonHash))
+	assert.False(t, weakArgon.NeedsRehash(strongArgonHash))
+	assert.True(t, strongArgon.NeedsRehash(weakBcryptHash))
+	assert.True(t, strongBcrypt.NeedsRehash(weakBcryptHash))
+	assert.True(t, strongBcrypt.NeedsRehash(strongArgonHash))
+	assert.True(t, strongArgon.NeedsRehash("not a hash"))
+}
+
+func TestBcryptReadsLegacyHashes(t *testing.T) {
+	hasher := &credentials.Bcrypt{Cost: bcrypt.MinCost}
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	ok, err := hasher.Verify(string(legacy), "password123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = hasher.Verify(string(legacy), "password124")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// Legacy hashes are rewritten as PHC strings, even at the same cost.
+	assert.True(t, hasher.NeedsRehash(string(legacy)))
+
+	_, err = hasher.Verify("$bcrypt$r=4$c2FsdA$a2V5", "password123")
+	assert.ErrorIs(t, err, credentials.ErrUnknownHash)
+}
+
+func TestLoginUpgradesLegacyHash(t *testing.T) {
+	router := setupRouter()
+	ctx := context.Background()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{
+		UserID:        utils.GenerateUUID(),
+		FirstName:     "Legacy",
+		LastName:      "User",
+		Email:         utils.GenerateUUID() + "@example.com",
+		Password:      string(legacy),
+		EmailVerified: true,
+	}
+	org := &models.Organisation{OrgID: utils.GenerateUUID(), Name: "Legacy's Organisation"}
+	require.NoError(t, testRepos.Users.Register(ctx, user, org))
+	changedAt := *user.PasswordChangedAt
+
+	// A wrong password leaves the hash alone.
+	w := postWithToken(router, "/auth/login", "", map[string]string{"email": user.Email, "password": "wrong-password"})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	stored, err := testRepos.Users.FindByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, string(legacy), stored.Password)
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": user.Email, "password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stored, err = testRepos.Users.FindByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$"), stored.Password)
+	assert.Equal(t, changedAt, *stored.PasswordChangedAt, "an upgrade is not a password change")
+
+	// The upgraded hash is also what the reuse check sees.
+	hashes, err := testRepos.Users.RecentPasswordHashes(ctx, user.UserID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{stored.Password}, hashes)
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": user.Email, "password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+	again, err := testRepos.Users.FindByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, stored.Password, again.Password, "a current hash is not rehashed")
+}
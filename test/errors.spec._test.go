@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"hng/apperr"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errorResponse struct {
+	Status     string              `json:"status"`
+	Code       string              `json:"code"`
+	Message    string              `json:"message"`
+	StatusCode int                 `json:"statusCode"`
+	Errors     []apperr.FieldError `json:"errors"`
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorResponse {
+	var response errorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	assert.Equal(t, "error", response.Status)
+	assert.Equal(t, w.Code, response.StatusCode)
+	return response
+}
+
+func TestErrorsShareOneEnvelope(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		status int
+		code   apperr.Code
+	}{
+		{"missing token", getWithToken(router, "/api/organisations", ""), http.StatusUnauthorized, apperr.AuthMissingToken},
+		{"validation", postWithToken(router, "/auth/login", "", map[string]string{"email": "not-an-email"}), http.StatusUnprocessableEntity, apperr.ValidationFailed},
+		{"unknown refresh token", postWithToken(router, "/auth/refresh", "", map[string]string{"refreshToken": "nope"}), http.StatusUnauthorized, apperr.AuthInvalidRefreshToken},
+		{"unknown route", getWithToken(router, "/api/nothing-here", ""), http.StatusNotFound, apperr.NotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.status, tc.w.Code)
+			response := decodeError(t, tc.w)
+			assert.Equal(t, string(tc.code), response.Code)
+			assert.NotEmpty(t, response.Message)
+		})
+	}
+}
+
+func TestValidationErrorsListFields(t *testing.T) {
+	router := setupRouter()
+
+	w := postWithToken(router, "/auth/login", "", map[string]string{"email": "not-an-email"})
+	response := decodeError(t, w)
+	assert.Len(t, response.Errors, 2)
+}
+
+func TestInternalErrorsHideTheirCause(t *testing.T) {
+	r := gin.New()
+	r.Use(apperr.Middleware())
+	r.GET("/fails", func(c *gin.Context) {
+		apperr.Abort(c, apperr.Internal(errors.New("connection refused"), "Could not load things"))
+	})
+	r.GET("/unexpected", func(c *gin.Context) {
+		c.Error(errors.New("connection refused"))
+	})
+
+	for _, path := range []string{"/fails", "/unexpected"} {
+		w := getWithToken(r, path, "")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		response := decodeError(t, w)
+		assert.Equal(t, string(apperr.InternalError), response.Code)
+		assert.NotContains(t, w.Body.String(), "connection refused")
+	}
+}
+
+func TestErrorCatalogueEndpoint(t *testing.T) {
+	router := setupRouter()
+
+	w := getWithToken(router, "/api/errors", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Errors []apperr.CatalogueEntry `json:"errors"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, apperr.Catalogue(), response.Data.Errors)
+
+	codes := map[apperr.Code]bool{}
+	for _, e := range response.Data.Errors {
+		codes[e.Code] = true
+		assert.NotZero(t, e.Status, e.Code)
+		assert.NotEmpty(t, e.Message, e.Code)
+	}
+	assert.True(t, codes[apperr.AuthInvalidCredentials])
+	assert.True(t, codes[apperr.OrgNotFound])
+}
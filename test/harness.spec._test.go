@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"hng/config"
+	"hng/controllers"
+	"hng/credentials"
+	"hng/lockout"
+	"hng/migrations"
+	"hng/models"
+	"hng/ratelimit"
+	"hng/repository"
+	"hng/routes"
+	"hng/utils"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// The router built by the last setupRouter call and what it is wired to.
+// Tests run one at a time, so every test starts from empty repositories.
+var (
+	testConfig  *config.Config
+	testRepos   *repository.Repositories
+	testHandler *controllers.Handler
+	hashPool    *credentials.Pool
+	loginGuard  *lockout.Guard
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	// config.Load insists on these. Nothing connects to them: tests that
+	// need Postgres use TEST_POSTGRES_DSN.
+	for key, value := range map[string]string{
+		"JWT_SECRET":    "test-secret-that-is-at-least-32-bytes",
+		"POSTGRES_HOST": "localhost",
+		"POSTGRES_USER": "test",
+		"POSTGRES_DB":   "test",
+	} {
+		if os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+// setupRouter builds the application router on in-memory repositories.
+func setupRouter() *gin.Engine {
+	return setupRouterOn(repository.NewMemory())
+}
+
+// setupRouterOn builds the application router on repos.
+func setupRouterOn(repos *repository.Repositories) *gin.Engine {
+	testConfig = config.Default()
+	utils.ConfigureTokens(testConfig.JWT)
+	testRepos = repos
+	sentMail = &testMailer{}
+	loginGuard = lockout.NewGuard(lockout.NewMemoryStore())
+
+	hasher, err := credentials.NewHasher(testConfig.Password)
+	if err != nil {
+		panic(err)
+	}
+	if hashPool != nil {
+		hashPool.Close()
+	}
+	hashPool = credentials.NewPool(testConfig.Password.HashConcurrency, testConfig.Password.HashQueueDepth)
+
+	testHandler = &controllers.Handler{
+		Repos:       testRepos,
+		Credentials: credentials.NewService(testRepos, hasher, hashPool),
+		Revocations: utils.NewRevocationStore(testRepos.Revocations),
+		Mailer:      sentMail,
+		Lockout:     loginGuard,
+		Config:      testConfig,
+	}
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(testConfig.Server.TrustedProxies); err != nil {
+		panic(err)
+	}
+	routes.Setup(r, testHandler, ratelimit.NewMemoryBackend())
+	return r
+}
+
+// postgresDB connects to the database in TEST_POSTGRES_DSN and migrates it,
+// or skips the test when none is configured.
+func postgresDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, models.SetupJoinTables(db))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := migrations.New(sqlDB, migrations.Files)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+// dryRunDB is a gorm handle that runs callbacks and model hooks but never
+// sends a statement, so it needs no database.
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=dry-run.invalid"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	return db
+}
+
+// createAdmin stores a verified admin directly, since admins cannot be
+// created through the API, and returns their login response data.
+func createAdmin(t *testing.T, router *gin.Engine) map[string]interface{} {
+	// The cost does not matter for checking the password and the minimum
+	// keeps the test fast.
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	admin := &models.User{
+		UserID:        utils.GenerateUUID(),
+		FirstName:     "Admin",
+		LastName:      "User",
+		Email:         "admin-" + utils.GenerateUUID() + "@example.com",
+		Password:      string(hash),
+		EmailVerified: true,
+		IsAdmin:       true,
+	}
+	org := &models.Organisation{OrgID: utils.GenerateUUID(), Name: "Admin's Organisation"}
+	require.NoError(t, testRepos.Users.Register(context.Background(), admin, org))
+
+	w := postWithToken(router, "/auth/login", "", map[string]string{"email": admin.Email, "password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response["data"].(map[string]interface{})
+}
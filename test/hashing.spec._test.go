@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"hng/apperr"
+	"hng/credentials"
+	"net/http"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// occupy blocks one worker of pool, or one queue slot once the workers are
+// busy, until release is closed. wg is done once the job has finished.
+func occupy(pool *credentials.Pool, release chan struct{}, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.Run(context.Background(), func() { <-release })
+	}()
+}
+
+func TestPoolRejectsWhenQueueIsFull(t *testing.T) {
+	pool := credentials.NewPool(1, 1)
+	defer pool.Close()
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+
+	occupy(pool, release, &wg)
+	require.Eventually(t, func() bool { return pool.Stats().Running == 1 }, time.Second, time.Millisecond)
+	occupy(pool, release, &wg)
+	require.Eventually(t, func() bool { return pool.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+	err := pool.Run(context.Background(), func() { t.Error("a rejected job must not run") })
+	assert.ErrorIs(t, err, credentials.ErrBusy)
+
+	close(release)
+	wg.Wait()
+	assert.NoError(t, pool.Run(context.Background(), func() {}))
+
+	stats := pool.Stats()
+	assert.Equal(t, int64(1), stats.Rejected)
+	assert.Equal(t, int64(3), stats.QueueWait.Count)
+	assert.Greater(t, stats.QueueWait.MaxSeconds, 0.0)
+	buckets := stats.QueueWait.Buckets
+	require.NotEmpty(t, buckets)
+	for i := 1; i < len(buckets); i++ {
+		assert.Greater(t, buckets[i].LE, buckets[i-1].LE)
+		assert.GreaterOrEqual(t, buckets[i].Count, buckets[i-1].Count)
+	}
+}
+
+func TestPoolWithoutQueueRunsOnFreeWorkers(t *testing.T) {
+	pool := credentials.NewPool(1, 0)
+	defer pool.Close()
+
+	// Nothing waits, but a free worker takes every job.
+	for i := 0; i < 100; i++ {
+		require.NoError(t, pool.Run(context.Background(), func() {}))
+	}
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	occupy(pool, release, &wg)
+	require.Eventually(t, func() bool { return pool.Stats().Running == 1 }, time.Second, time.Millisecond)
+	err := pool.Run(context.Background(), func() { t.Error("a rejected job must not run") })
+	assert.ErrorIs(t, err, credentials.ErrBusy)
+
+	close(release)
+	wg.Wait()
+	assert.NoError(t, pool.Run(context.Background(), func() {}))
+	assert.Equal(t, int64(1), pool.Stats().Rejected)
+}
+
+func TestPoolRejectsJobsAfterClose(t *testing.T) {
+	pool := credentials.NewPool(1, 1)
+	pool.Close()
+
+	// A request still running after a shutdown timed out must not panic.
+	err := pool.Run(context.Background(), func() { t.Error("a job after Close must not run") })
+	assert.ErrorIs(t, err, credentials.ErrClosed)
+	pool.Close()
+}
+
+func TestPoolSkipsJobsOfCancelledRequests(t *testing.T) {
+	pool := credentials.NewPool(1, 1)
+	defer pool.Close()
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+
+	occupy(pool, release, &wg)
+	require.Eventually(t, func() bool { return pool.Stats().Running == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	done := make(chan error)
+	go func() { done <- pool.Run(ctx, func() { ran = true }) }()
+	require.Eventually(t, func() bool { return pool.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+	// The client disconnects while the job is still queued.
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	close(release)
+	wg.Wait()
+	require.Eventually(t, func() bool { return pool.Stats().Cancelled == 1 }, time.Second, time.Millisecond)
+	assert.False(t, ran)
+}
+
+func TestBusyHashingReturnsServiceUnavailable(t *testing.T) {
+	router := setupRouter()
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(release)
+
+	workers := runtime.NumCPU()
+	for i := 0; i < workers; i++ {
+		occupy(hashPool, release, &wg)
+	}
+	require.Eventually(t, func() bool { return hashPool.Stats().Running == int64(workers) }, time.Second, time.Millisecond)
+	for i := 0; i < testConfig.Password.HashQueueDepth; i++ {
+		occupy(hashPool, release, &wg)
+	}
+	require.Eventually(t, func() bool { return hashPool.Stats().Queued == testConfig.Password.HashQueueDepth }, time.Second, time.Millisecond)
+
+	w := postWithToken(router, "/auth/register", "", map[string]string{
+		"firstName": "Jane", "lastName": "Doe", "email": "jane@example.com", "password": "password123",
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	response := decodeError(t, w)
+	assert.Equal(t, string(apperr.ServerBusy), response.Code)
+}
+
+func TestAdminMetricsReportHashing(t *testing.T) {
+	router := setupRouter()
+	admin := createAdmin(t, router)
+
+	w := getWithToken(router, "/api/admin/metrics", admin["accessToken"].(string))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			PasswordHashing credentials.PoolStats `json:"passwordHashing"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	stats := response.Data.PasswordHashing
+	assert.GreaterOrEqual(t, stats.Completed, int64(1))
+	assert.Equal(t, stats.Completed, stats.QueueWait.Count)
+
+	user := registerTestUser(t, router)
+	w = getWithToken(router, "/api/admin/metrics", user["accessToken"].(string))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"hng/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRSAKey(t *testing.T, dir, kid string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(dir, kid+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func writeECKey(t *testing.T, dir, kid string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, kid+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+	return path
+}
+
+func useKeys(t *testing.T, provider utils.KeyProvider) {
+	previous := utils.Keys()
+	utils.SetKeyProvider(provider)
+	t.Cleanup(func() { utils.SetKeyProvider(previous) })
+}
+
+func TestAsymmetricSigningKeys(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{writeRSAKey(t, dir, "rsa-1"), writeECKey(t, dir, "ec-1")} {
+		provider, err := utils.LoadKeyProvider([]string{path}, "")
+		require.NoError(t, err)
+		useKeys(t, provider)
+
+		tokenString, err := utils.GenerateToken(utils.GenerateUUID(), "test@example.com")
+		require.NoError(t, err)
+
+		token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &utils.Claims{})
+		require.NoError(t, err)
+		key, _ := provider.SigningKey()
+		assert.Equal(t, key.ID, token.Header["kid"])
+		assert.Equal(t, key.Method.Alg(), token.Header["alg"])
+
+		claims, err := utils.ValidateToken(tokenString)
+		assert.NoError(t, err)
+		assert.Equal(t, "test@example.com", claims.Email)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := writeRSAKey(t, dir, "2024-01")
+	newKey := writeECKey(t, dir, "2024-06")
+
+	provider, err := utils.LoadKeyProvider([]string{oldKey}, "")
+	require.NoError(t, err)
+	useKeys(t, provider)
+	oldToken, err := utils.GenerateToken(utils.GenerateUUID(), "test@example.com")
+	require.NoError(t, err)
+
+	// After rotation tokens signed with the previous key still verify
+	provider, err = utils.LoadKeyProvider([]string{oldKey, newKey}, "2024-06")
+	require.NoError(t, err)
+	useKeys(t, provider)
+	_, err = utils.ValidateToken(oldToken)
+	assert.NoError(t, err)
+
+	// Once the old key is retired its tokens are rejected
+	provider, err = utils.LoadKeyProvider([]string{newKey}, "")
+	require.NoError(t, err)
+	useKeys(t, provider)
+	_, err = utils.ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestRejectsAlgorithmMismatch(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := utils.LoadKeyProvider([]string{writeRSAKey(t, dir, "rsa-1")}, "")
+	require.NoError(t, err)
+	useKeys(t, provider)
+
+	// An HS256 token using the public key as the shared secret must not verify
+	key, _ := provider.SigningKey()
+	der, _ := x509.MarshalPKIXPublicKey(key.Public)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &utils.Claims{Email: "test@example.com"})
+	forged.Header["kid"] = "rsa-1"
+	tokenString, err := forged.SignedString(der)
+	require.NoError(t, err)
+
+	_, err = utils.ValidateToken(tokenString)
+	assert.Error(t, err)
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := utils.LoadKeyProvider([]string{writeRSAKey(t, dir, "rsa-1"), writeECKey(t, dir, "ec-1")}, "ec-1")
+	require.NoError(t, err)
+	useKeys(t, provider)
+
+	r := setupRouter()
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Keys []utils.JWK `json:"keys"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	require.Len(t, response.Keys, 2)
+	assert.Equal(t, "rsa-1", response.Keys[0].Kid)
+	assert.Equal(t, "RSA", response.Keys[0].Kty)
+	assert.Equal(t, "RS256", response.Keys[0].Alg)
+	assert.NotEmpty(t, response.Keys[0].N)
+	assert.Equal(t, "ec-1", response.Keys[1].Kid)
+	assert.Equal(t, "EC", response.Keys[1].Kty)
+	assert.Equal(t, "P-256", response.Keys[1].Crv)
+	assert.NotEmpty(t, response.Keys[1].X)
+}
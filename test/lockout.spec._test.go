@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func loginFrom(router *gin.Engine, ip, email, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func emailOf(data map[string]interface{}) string {
+	return data["user"].(map[string]interface{})["email"].(string)
+}
+
+func useClock(now *time.Time) {
+	loginGuard.Now = func() time.Time { return *now }
+}
+
+func TestLoginBacksOffAfterRepeatedFailures(t *testing.T) {
+	router := setupRouter()
+	now := time.Now()
+	useClock(&now)
+	email := emailOf(registerTestUser(t, router))
+
+	for i := 0; i < loginGuard.Account.FreeAttempts+1; i++ {
+		w := loginFrom(router, "198.51.100.1", email, "wrong-password")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// Even the right password is refused while the account is backing off.
+	w := loginFrom(router, "198.51.100.1", email, "password123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, retryAfter)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(http.StatusTooManyRequests), response["statusCode"])
+
+	// Another failure doubles the delay.
+	now = now.Add(2 * time.Second)
+	loginFrom(router, "198.51.100.1", email, "wrong-password")
+	w = loginFrom(router, "198.51.100.1", email, "password123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	now = now.Add(3 * time.Second)
+	w = loginFrom(router, "198.51.100.1", email, "password123")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A successful login starts the account's count again.
+	w = loginFrom(router, "198.51.100.1", email, "wrong-password")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = loginFrom(router, "198.51.100.1", email, "password123")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLoginLocksOutClientIP(t *testing.T) {
+	router := setupRouter()
+	now := time.Now()
+	useClock(&now)
+
+	// Spraying many accounts from one address blocks that address only.
+	for i := 0; i < loginGuard.IP.FreeAttempts+1; i++ {
+		w := loginFrom(router, "198.51.100.2", "nobody"+strconv.Itoa(i)+"@example.com", "password123")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	w := loginFrom(router, "198.51.100.2", "someone-else@example.com", "password123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w = loginFrom(router, "198.51.100.3", "someone-else@example.com", "password123")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLoginIPLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	router := setupRouter()
+	now := time.Now()
+	useClock(&now)
+
+	// Claiming a new address on every guess does not escape the lockout
+	// on the real one.
+	var w *httptest.ResponseRecorder
+	for i := 0; i < loginGuard.IP.FreeAttempts+2; i++ {
+		body, _ := json.Marshal(map[string]string{"email": "nobody" + strconv.Itoa(i) + "@example.com", "password": "password123"})
+		req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "203.0.113."+strconv.Itoa(i))
+		req.RemoteAddr = "198.51.100.4:12345"
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestAdminCanUnlockAccount(t *testing.T) {
+	router := setupRouter()
+	now := time.Now()
+	useClock(&now)
+	loginGuard.Account.LockoutThreshold = 3
+
+	admin := createAdmin(t, router)
+	user := registerTestUser(t, router)
+	email := emailOf(user)
+
+	for i := 0; i < 3; i++ {
+		loginFrom(router, "198.51.100.4", email, "wrong-password")
+	}
+	w := loginFrom(router, "198.51.100.5", email, "password123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, strconv.Itoa(int(loginGuard.Account.LockoutDuration.Seconds())), w.Header().Get("Retry-After"))
+
+	// Only admins may unlock.
+	w = postWithToken(router, "/api/admin/users/"+userIDOf(user)+"/unlock", user["accessToken"].(string), nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = postWithToken(router, "/api/admin/users/"+userIDOf(user)+"/unlock", admin["accessToken"].(string), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = loginFrom(router, "198.51.100.5", email, "password123")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLoginMFAFailuresCount(t *testing.T) {
+	router := setupRouter()
+	now := time.Now()
+	useClock(&now)
+
+	data := registerTestUser(t, router)
+	enableTwoFactor(t, router, data["accessToken"].(string))
+	mfaToken := loginForMFAToken(t, router, emailOf(data))
+
+	for i := 0; i < loginGuard.Account.FreeAttempts+1; i++ {
+		w := postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	w := postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestPasswordLoginDoesNotClearMFAFailures(t *testing.T) {
+	router := setupRouter()
+	now := time.Now()
+	useClock(&now)
+
+	data := registerTestUser(t, router)
+	enableTwoFactor(t, router, data["accessToken"].(string))
+	mfaToken := loginForMFAToken(t, router, emailOf(data))
+
+	for i := 0; i < loginGuard.Account.FreeAttempts; i++ {
+		w := postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// The password alone is not a successful login.
+	mfaToken = loginForMFAToken(t, router, emailOf(data))
+
+	w := postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestPasswordConfirmationsCountAsFailedLogins(t *testing.T) {
+	router := setupRouter()
+	now := time.Now()
+	useClock(&now)
+
+	data := registerTestUser(t, router)
+	token := data["accessToken"].(string)
+	enableTwoFactor(t, router, token)
+
+	for i := 0; i < loginGuard.Account.FreeAttempts; i++ {
+		w := postWithToken(router, "/api/users/me/password", token, map[string]string{"currentPassword": "wrong", "newPassword": "newpassword456"})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	}
+	w := postWithToken(router, "/api/users/me/2fa/disable", token, map[string]string{"password": "wrong", "code": "000000"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// The account now backs off, for logins and confirmations alike.
+	for _, w := range []*httptest.ResponseRecorder{
+		postWithToken(router, "/api/users/me/password", token, map[string]string{"currentPassword": "password123", "newPassword": "newpassword456"}),
+		postWithToken(router, "/api/users/me/2fa/disable", token, map[string]string{"password": "password123", "code": "000000"}),
+		loginFrom(router, "198.51.100.9", emailOf(data), "password123"),
+	} {
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+		assert.Equal(t, "AUTH_TOO_MANY_ATTEMPTS", decodeError(t, w).Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/json"
+	"hng/utils"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The SHA1 test key from RFC 6238, base32 encoded
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	code, err := utils.TOTPCode(secret, time.Unix(59, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, _ = utils.TOTPCode(secret, time.Unix(1111111109, 0))
+	assert.Equal(t, "081804", code)
+
+	_, ok := utils.ValidateTOTP(secret, "081804", time.Unix(1111111109+30, 0))
+	assert.True(t, ok, "codes from the previous step are accepted")
+	_, ok = utils.ValidateTOTP(secret, "081804", time.Unix(1111111109+90, 0))
+	assert.False(t, ok)
+}
+
+// enableTwoFactor enrolls the user and returns the secret and recovery codes.
+func enableTwoFactor(t *testing.T, router *gin.Engine, token string) (string, []string) {
+	w := postWithToken(router, "/api/users/me/2fa/setup", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var setup struct {
+		Data struct {
+			Secret     string `json:"secret"`
+			OtpauthURI string `json:"otpauthUri"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &setup)
+	assert.True(t, strings.HasPrefix(setup.Data.OtpauthURI, "otpauth://totp/"))
+
+	code, _ := utils.TOTPCode(setup.Data.Secret, time.Now())
+	w = postWithToken(router, "/api/users/me/2fa/confirm", token, map[string]string{"code": code})
+	require.Equal(t, http.StatusOK, w.Code)
+	var confirm struct {
+		Data struct {
+			RecoveryCodes []string `json:"recoveryCodes"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &confirm)
+	return setup.Data.Secret, confirm.Data.RecoveryCodes
+}
+
+func loginForMFAToken(t *testing.T, router *gin.Engine, email string) string {
+	w := postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, true, data["mfaRequired"])
+	assert.Nil(t, data["accessToken"])
+	return data["mfaToken"].(string)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+	secret, recoveryCodes := enableTwoFactor(t, router, data["accessToken"].(string))
+	assert.Len(t, recoveryCodes, 10)
+
+	mfaToken := loginForMFAToken(t, router, email)
+
+	// The pending token is not an access token
+	w := getWithToken(router, "/api/organisations", mfaToken)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The code used to confirm enrollment cannot be replayed
+	code, _ := utils.TOTPCode(secret, time.Now())
+	w = postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": code})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	code, _ = utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	w = postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": code})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NotEmpty(t, response["data"].(map[string]interface{})["accessToken"])
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+	_, recoveryCodes := enableTwoFactor(t, router, data["accessToken"].(string))
+
+	mfaToken := loginForMFAToken(t, router, email)
+	w := postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": strings.ToUpper(recoveryCodes[0])})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Each recovery code works once
+	w = postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": mfaToken, "code": recoveryCodes[0]})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postWithToken(router, "/auth/login/mfa", "", map[string]string{"mfaToken": "invalid", "code": recoveryCodes[1]})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+	token := data["accessToken"].(string)
+	_, recoveryCodes := enableTwoFactor(t, router, token)
+
+	w := postWithToken(router, "/api/users/me/2fa/setup", token, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postWithToken(router, "/api/users/me/2fa/disable", token, map[string]string{"password": "wrong", "code": recoveryCodes[0]})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = postWithToken(router, "/api/users/me/2fa/disable", token, map[string]string{"password": "password123", "code": recoveryCodes[0]})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": "password123"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NotEmpty(t, response["data"].(map[string]interface{})["accessToken"])
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"context"
+	"hng/migrations"
+	"hng/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestEmbeddedMigrationsAreSequential(t *testing.T) {
+	all, err := migrations.Load(migrations.Files)
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	for i, mig := range all {
+		assert.Equal(t, int64(i+1), mig.Version, mig.Name)
+		assert.NotEmpty(t, mig.Up)
+		assert.NotEmpty(t, mig.Down)
+	}
+}
+
+func TestLoadRejectsMigrationWithoutDown(t *testing.T) {
+	_, err := migrations.Load(fstest.MapFS{
+		"0001_first.up.sql":   {Data: []byte("SELECT 1;")},
+		"0001_first.down.sql": {Data: []byte("SELECT 1;")},
+		"0002_second.up.sql":  {Data: []byte("SELECT 1;")},
+	})
+	assert.ErrorContains(t, err, "0002_second")
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	up, down, err := migrations.Create(dir, "add_widgets")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0001_add_widgets.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0001_add_widgets.down.sql"), down)
+
+	up, _, err = migrations.Create(dir, "drop_widgets")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0002_drop_widgets.up.sql"), up)
+
+	all, err := migrations.Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	_, _, err = migrations.Create(dir, "Bad Name")
+	assert.Error(t, err)
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	sqlDB, err := postgresDB(t).DB()
+	require.NoError(t, err)
+	m, err := migrations.New(sqlDB, migrations.Files)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, s.Name)
+	}
+	latest := statuses[len(statuses)-1]
+
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, latest.Version, reverted[0].Version)
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, statuses[len(statuses)-1].AppliedAt)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, latest.Version, applied[0].Version)
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+// withSearchPath points dsn, in URL or keyword form, at schema.
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+// legacyDB creates a schema of its own holding the tables AutoMigrate
+// created before email verification and membership roles existed, and
+// connects to it. It skips the test when no database is configured.
+func legacyDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	schema := "legacy_" + strings.ReplaceAll(utils.GenerateUUID(), "-", "")
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.Exec(`CREATE TABLE users (
+		id bigserial PRIMARY KEY, created_at timestamptz, updated_at timestamptz, deleted_at timestamptz,
+		user_id text UNIQUE, first_name text, last_name text, email text UNIQUE, password text, phone text)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE organisations (
+		id bigserial PRIMARY KEY, created_at timestamptz, updated_at timestamptz, deleted_at timestamptz,
+		org_id text UNIQUE, name text, description text)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE user_organisations (
+		organisation_id bigint, user_id bigint, PRIMARY KEY (organisation_id, user_id))`).Error)
+	return db
+}
+
+func migrateLegacy(t *testing.T, db *gorm.DB) {
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	m, err := migrations.New(sqlDB, migrations.Files)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+}
+
+func TestMigrationsUpgradeLegacyUsers(t *testing.T) {
+	db := legacyDB(t)
+	require.NoError(t, db.Exec(`INSERT INTO users (created_at, user_id, email, password) VALUES (now(), 'legacy', 'legacy@example.com', 'hash')`).Error)
+
+	migrateLegacy(t, db)
+
+	var legacy struct {
+		EmailVerified   bool
+		EmailVerifiedAt *string
+	}
+	require.NoError(t, db.Raw("SELECT email_verified, email_verified_at::text FROM users WHERE user_id = 'legacy'").Scan(&legacy).Error)
+	assert.True(t, legacy.EmailVerified, "accounts from before verification can still log in")
+	assert.NotNil(t, legacy.EmailVerifiedAt)
+
+	// Accounts registered from now on still start unverified.
+	require.NoError(t, db.Exec(`INSERT INTO users (created_at, user_id, email, password) VALUES (now(), 'new', 'new@example.com', 'hash')`).Error)
+	var verified bool
+	require.NoError(t, db.Raw("SELECT email_verified FROM users WHERE user_id = 'new'").Scan(&verified).Error)
+	assert.False(t, verified)
+}
+
+func TestMigrationsGiveLegacyOrganisationsAnOwner(t *testing.T) {
+	db := legacyDB(t)
+	require.NoError(t, db.Exec(`INSERT INTO users (id, created_at, user_id, email) VALUES
+		(1, now(), 'alice', 'alice@example.com'),
+		(2, now(), 'bob', 'bob@example.com')`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO organisations (id, created_at, org_id, name) VALUES
+		(1, now(), 'alice-org', 'Alice''s Organisation'),
+		(2, now(), 'bob-org', 'Bob''s Organisation')`).Error)
+	// Bob was added to Alice's organisation after registering his own.
+	require.NoError(t, db.Exec(`INSERT INTO user_organisations (organisation_id, user_id) VALUES
+		(1, 1), (2, 2), (1, 2)`).Error)
+
+	migrateLegacy(t, db)
+
+	roles := func(orgID int) map[int]string {
+		var rows []struct {
+			UserID int
+			Role   string
+		}
+		require.NoError(t, db.Raw("SELECT user_id, role FROM user_organisations WHERE organisation_id = ?", orgID).Scan(&rows).Error)
+		byUser := map[int]string{}
+		for _, row := range rows {
+			byUser[row.UserID] = row.Role
+		}
+		return byUser
+	}
+	assert.Equal(t, map[int]string{1: "owner", 2: "member"}, roles(1))
+	assert.Equal(t, map[int]string{2: "owner"}, roles(2))
+}
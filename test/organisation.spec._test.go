@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func getWithToken(router *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func createTestOrganisation(t *testing.T, router *gin.Engine, token string) string {
+	w := postWithToken(router, "/api/organisations", token, map[string]string{"name": "Test Organisation"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response["data"].(map[string]interface{})["orgId"].(string)
+}
+
+func userIDOf(data map[string]interface{}) string {
+	return data["user"].(map[string]interface{})["userId"].(string)
+}
+
+func TestOrganisationCreatorIsOwner(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	other := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+
+	w := postWithToken(router, "/api/organisations/"+orgID+"/users", owner["accessToken"].(string),
+		map[string]string{"userId": userIDOf(other)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = getWithToken(router, "/api/organisations/"+orgID, other["accessToken"].(string))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMemberCannotAddUsers(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	member := registerTestUser(t, router)
+	outsider := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+
+	w := postWithToken(router, "/api/organisations/"+orgID+"/users", owner["accessToken"].(string),
+		map[string]string{"userId": userIDOf(member)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, "/api/organisations/"+orgID+"/users", member["accessToken"].(string),
+		map[string]string{"userId": userIDOf(outsider)})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestNonMemberCannotAccessOrganisation(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	outsider := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+
+	w := getWithToken(router, "/api/organisations/"+orgID, outsider["accessToken"].(string))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = postWithToken(router, "/api/organisations/"+orgID+"/users", outsider["accessToken"].(string),
+		map[string]string{"userId": userIDOf(outsider)})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListOrganisationsOnlyReturnsMemberships(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	outsider := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+
+	listOrgIDs := func(token string) []string {
+		w := getWithToken(router, "/api/organisations", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data struct {
+				Organisations []struct {
+					OrgID string `json:"orgId"`
+				} `json:"organisations"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		var ids []string
+		for _, org := range response.Data.Organisations {
+			ids = append(ids, org.OrgID)
+		}
+		return ids
+	}
+
+	// The owner sees their default organisation and the new one
+	assert.Len(t, listOrgIDs(owner["accessToken"].(string)), 2)
+	assert.Contains(t, listOrgIDs(owner["accessToken"].(string)), orgID)
+
+	// The outsider only sees their own default organisation
+	assert.Len(t, listOrgIDs(outsider["accessToken"].(string)), 1)
+	assert.NotContains(t, listOrgIDs(outsider["accessToken"].(string)), orgID)
+}
+
+func TestAddUserToOrganisationRequiresAuth(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+
+	w := postWithToken(router, "/api/organisations/"+orgID+"/users", "", map[string]string{"userId": userIDOf(owner)})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAddUserToOrganisationIsIdempotent(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	member := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+	path := "/api/organisations/" + orgID + "/users"
+
+	w := postWithToken(router, path, owner["accessToken"].(string), map[string]string{"userId": userIDOf(member)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, path, owner["accessToken"].(string), map[string]string{"userId": userIDOf(member)})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "User is already a member of this organisation", response["message"])
+
+	w = postWithToken(router, path, owner["accessToken"].(string), map[string]string{"userId": userIDOf(member), "role": "admin"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestAddUserToOrganisationRoleLimits(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	admin := registerTestUser(t, router)
+	other := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+	path := "/api/organisations/" + orgID + "/users"
+
+	w := postWithToken(router, path, owner["accessToken"].(string), map[string]string{"userId": userIDOf(admin), "role": "admin"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Admins may add members but not owners
+	w = postWithToken(router, path, admin["accessToken"].(string), map[string]string{"userId": userIDOf(other), "role": "owner"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = postWithToken(router, path, admin["accessToken"].(string), map[string]string{"userId": userIDOf(other)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, path, owner["accessToken"].(string), map[string]string{"userId": "unknown"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = postWithToken(router, path, owner["accessToken"].(string), map[string]string{"userId": userIDOf(other), "role": "superuser"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestOrganisationCollectionServesBothPaths(t *testing.T) {
+	router := setupRouter()
+	token := registerTestUser(t, router)["accessToken"].(string)
+
+	for _, path := range []string{"/api/organisations", "/api/organisations/"} {
+		w := postWithToken(router, path, token, map[string]string{"name": "Test Organisation"})
+		assert.Equal(t, http.StatusCreated, w.Code, path)
+		w = getWithToken(router, path, token)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"encoding/json"
+	"hng/apperr"
+	"hng/config"
+	"hng/credentials"
+	"hng/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+
+	known := postWithToken(router, "/auth/forgot-password", "", map[string]string{"email": email})
+	unknown := postWithToken(router, "/auth/forgot-password", "", map[string]string{"email": "nobody@example.com"})
+
+	assert.Equal(t, http.StatusOK, known.Code)
+	assert.Equal(t, known.Code, unknown.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+	_, sent := sentMail.lastMessage("nobody@example.com")
+	assert.False(t, sent)
+}
+
+func TestForgotPasswordDoesNotWaitForMail(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+
+	assertAnswersWithoutMail(t, router, "/auth/forgot-password", map[string]string{"email": email})
+}
+
+func TestResetPassword(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+
+	w := postWithToken(router, "/auth/forgot-password", "", map[string]string{"email": email})
+	assert.Equal(t, http.StatusOK, w.Code)
+	token := mailedToken(t, email)
+
+	w = postWithToken(router, "/auth/reset-password", "", map[string]string{"token": token, "password": "newpassword456"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The token cannot be used twice
+	w = postWithToken(router, "/auth/reset-password", "", map[string]string{"token": token, "password": "otherpassword789"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Existing sessions are revoked
+	w = getWithToken(router, "/api/organisations", data["accessToken"].(string))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = refreshTokens(router, data["refreshToken"].(string))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Only the new password works
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": "password123"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": "newpassword456"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestResetPasswordRejectsSupersededToken(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+
+	postWithToken(router, "/auth/forgot-password", "", map[string]string{"email": email})
+	oldToken := mailedToken(t, email)
+	postWithToken(router, "/auth/forgot-password", "", map[string]string{"email": email})
+
+	w := postWithToken(router, "/auth/reset-password", "", map[string]string{"token": oldToken, "password": "newpassword456"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = postWithToken(router, "/auth/reset-password", "", map[string]string{"token": "invalid", "password": "newpassword456"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func errorFields(t *testing.T, w *httptest.ResponseRecorder) []string {
+	var response struct {
+		Errors []apperr.FieldError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	var fields []string
+	for _, e := range response.Errors {
+		fields = append(fields, e.Field)
+	}
+	return fields
+}
+
+func TestRegisterEnforcesPasswordPolicy(t *testing.T) {
+	router := setupRouter()
+
+	w := postWithToken(router, "/auth/register", "", map[string]string{
+		"firstName": "Test", "lastName": "User", "email": "user-" + utils.GenerateUUID() + "@example.com", "password": "a",
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	fields := errorFields(t, w)
+	assert.Contains(t, fields, "password")
+	assert.Len(t, fields, 2)
+}
+
+func TestPasswordLongerThanBcryptAllowsIsAPolicyViolation(t *testing.T) {
+	router := setupRouter()
+	testHandler.Credentials = credentials.NewService(testRepos, &credentials.Bcrypt{Cost: bcrypt.MinCost}, hashPool)
+	cfg := config.Default().Password
+	cfg.Algorithm = "bcrypt"
+	policy, err := utils.NewPasswordPolicy(cfg)
+	require.NoError(t, err)
+	utils.SetPasswordPolicy(policy)
+	defer utils.SetPasswordPolicy(utils.DefaultPasswordPolicy())
+	data := registerTestUser(t, router)
+	token := data["accessToken"].(string)
+
+	tooLong := strings.Repeat("a1", 36) + "b"
+	w := postWithToken(router, "/auth/register", "", map[string]string{
+		"firstName": "Test", "lastName": "User", "email": "user-" + utils.GenerateUUID() + "@example.com", "password": tooLong,
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, []apperr.FieldError{
+		{Field: "password", Message: "Password must be at most 72 bytes long"},
+	}, decodeError(t, w).Errors)
+
+	w = postWithToken(router, "/api/users/me/password", token, map[string]string{"currentPassword": "password123", "newPassword": tooLong})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, []string{"newPassword"}, errorFields(t, w))
+
+	w = postWithToken(router, "/api/users/me/password", token, map[string]string{"currentPassword": "password123", "newPassword": tooLong[:72]})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPasswordMaxLengthFollowsAlgorithm(t *testing.T) {
+	cfg := config.Default().Password
+	assert.Equal(t, 256, cfg.MaxPasswordLength())
+	cfg.Algorithm = "bcrypt"
+	assert.Equal(t, 72, cfg.MaxPasswordLength())
+	cfg.MaxLength = 64
+	assert.Equal(t, 64, cfg.MaxPasswordLength())
+
+	// A long passphrase is fine under the default argon2id.
+	assert.NoError(t, utils.DefaultPasswordPolicy().Validate("password", strings.Repeat("a1", 100)))
+}
+
+func TestPasswordBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# common passwords\nPassword123\nletmein1\n"), 0600))
+	t.Setenv("PASSWORD_BLOCKLIST_FILE", path)
+	t.Setenv("PASSWORD_MIN_LENGTH", "10")
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	policy, err := utils.NewPasswordPolicy(cfg.Password)
+	require.NoError(t, err)
+	assert.Equal(t, 10, policy.MinLength)
+	assert.Error(t, policy.Validate("password", "password123"))
+	assert.Error(t, policy.Validate("password", "short1"))
+	assert.NoError(t, policy.Validate("password", "correcthorse9"))
+}
+
+func TestChangePassword(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+	token := data["accessToken"].(string)
+
+	w := postWithToken(router, "/api/users/me/password", token, map[string]string{"currentPassword": "wrong", "newPassword": "newpassword456"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, []string{"currentPassword"}, errorFields(t, w))
+
+	// The current password counts as a previous password
+	w = postWithToken(router, "/api/users/me/password", token, map[string]string{"currentPassword": "password123", "newPassword": "password123"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, []string{"newPassword"}, errorFields(t, w))
+
+	w = postWithToken(router, "/api/users/me/password", token, map[string]string{"currentPassword": "password123", "newPassword": "newpassword456"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": "newpassword456"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, "/api/users/me/password", "", map[string]string{"currentPassword": "newpassword456", "newPassword": "another789"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
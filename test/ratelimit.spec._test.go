@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"hng/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketRefills(t *testing.T) {
+	backend := ratelimit.NewMemoryBackend()
+	limit := ratelimit.Limit{Requests: 2, Per: 10 * time.Second}
+	now := time.Now()
+	ctx := context.Background()
+
+	res, _ := backend.Take(ctx, "k", limit, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	res, _ = backend.Take(ctx, "k", limit, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 10*time.Second, res.Reset)
+
+	res, _ = backend.Take(ctx, "k", limit, now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 5*time.Second, res.RetryAfter)
+
+	// Other keys have their own bucket.
+	res, _ = backend.Take(ctx, "other", limit, now)
+	assert.True(t, res.Allowed)
+
+	res, _ = backend.Take(ctx, "k", limit, now.Add(5*time.Second))
+	assert.True(t, res.Allowed)
+}
+
+func refreshFrom(router *gin.Engine, ip string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/auth/refresh", strings.NewReader(`{"refreshToken":"not-a-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthRoutesRateLimitedByIP(t *testing.T) {
+	router := setupRouter()
+	limit := testConfig.RateLimit.Auth
+
+	for i := 0; i < limit; i++ {
+		w := refreshFrom(router, "198.51.100.10")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, strconv.Itoa(limit), w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(limit-i-1), w.Header().Get("RateLimit-Remaining"))
+		assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+	}
+
+	// The limit covers the whole group, not just one endpoint.
+	w := loginFrom(router, "198.51.100.10", "nobody@example.com", "password123")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Other clients are unaffected.
+	w = refreshFrom(router, "198.51.100.11")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	router := setupRouter()
+
+	// No proxies are trusted by default, so a client cannot pick a fresh
+	// bucket by claiming a different address on every request.
+	limited := false
+	for i := 0; i <= testConfig.RateLimit.Auth; i++ {
+		req, _ := http.NewRequest("POST", "/auth/refresh", strings.NewReader(`{"refreshToken":"not-a-token"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "203.0.113."+strconv.Itoa(i))
+		req.RemoteAddr = "198.51.100.12:12345"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		limited = w.Code == http.StatusTooManyRequests
+	}
+	assert.True(t, limited)
+}
+
+func TestUserRoutesRateLimitedByUser(t *testing.T) {
+	router := setupRouter()
+	first := registerTestUser(t, router)
+	second := registerTestUser(t, router)
+
+	for i := 0; i < testConfig.RateLimit.Users; i++ {
+		w := getWithToken(router, "/api/users/"+userIDOf(first), first["accessToken"].(string))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	w := getWithToken(router, "/api/users/"+userIDOf(first), first["accessToken"].(string))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Both users share an address but not a bucket.
+	w = getWithToken(router, "/api/users/"+userIDOf(second), second["accessToken"].(string))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAdminRoutesRateLimitedByUser(t *testing.T) {
+	router := setupRouter()
+	admin := createAdmin(t, router)
+	token := admin["accessToken"].(string)
+
+	for i := 0; i < testConfig.RateLimit.Admin; i++ {
+		w := getWithToken(router, "/api/admin/metrics", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	w := getWithToken(router, "/api/admin/metrics", token)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestWellKnownRoutesRateLimitedByIP(t *testing.T) {
+	router := setupRouter()
+	get := func(ip string) int {
+		req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		req.RemoteAddr = ip + ":12345"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < testConfig.RateLimit.WellKnown; i++ {
+		assert.Equal(t, http.StatusOK, get("198.51.100.20"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get("198.51.100.20"))
+	assert.Equal(t, http.StatusOK, get("198.51.100.21"))
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"context"
+	"hng/models"
+	"hng/repository"
+	"hng/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eachRepository runs fn against the in-memory and the gorm repositories so
+// both are held to the same contract.
+func eachRepository(t *testing.T, fn func(t *testing.T, repos *repository.Repositories)) {
+	t.Run("memory", func(t *testing.T) { fn(t, repository.NewMemory()) })
+	t.Run("gorm", func(t *testing.T) { fn(t, repository.NewGorm(postgresDB(t))) })
+}
+
+func registerRepositoryUser(t *testing.T, repos *repository.Repositories) (*models.User, *models.Organisation) {
+	user := &models.User{
+		UserID:    utils.GenerateUUID(),
+		FirstName: "Repo",
+		Email:     utils.GenerateUUID() + "@example.com",
+		Password:  "hash-0",
+	}
+	org := &models.Organisation{OrgID: utils.GenerateUUID(), Name: "Repo's Organisation"}
+	require.NoError(t, repos.Users.Register(context.Background(), user, org))
+	return user, org
+}
+
+func TestRepositoryRegister(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		user, org := registerRepositoryUser(t, repos)
+
+		found, err := repos.Users.FindByEmail(ctx, user.Email)
+		require.NoError(t, err)
+		assert.Equal(t, user.UserID, found.UserID)
+		assert.Equal(t, "hash-0", found.Password)
+
+		_, err = repos.Users.FindByID(ctx, utils.GenerateUUID())
+		assert.Equal(t, repository.ErrNotFound, err)
+
+		duplicate := &models.User{UserID: utils.GenerateUUID(), Email: user.Email, Password: "hash"}
+		err = repos.Users.Register(ctx, duplicate, &models.Organisation{OrgID: utils.GenerateUUID(), Name: "Duplicate"})
+		assert.Equal(t, repository.ErrDuplicateEmail, err)
+
+		membership, err := repos.Organisations.FindMembership(ctx, org.OrgID, user.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, models.RoleOwner, membership.Role)
+
+		organisations, err := repos.Organisations.ListForUser(ctx, user.UserID)
+		require.NoError(t, err)
+		require.Len(t, organisations, 1)
+		assert.Equal(t, org.OrgID, organisations[0].OrgID)
+	})
+}
+
+func TestRepositoryPasswordHistory(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		user, _ := registerRepositoryUser(t, repos)
+
+		require.NoError(t, repos.Users.SetPassword(ctx, user.UserID, "hash-1", 2))
+		require.NoError(t, repos.Users.SetPassword(ctx, user.UserID, "hash-2", 2))
+
+		hashes, err := repos.Users.RecentPasswordHashes(ctx, user.UserID, 5)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"hash-2", "hash-1"}, hashes)
+
+		found, err := repos.Users.FindByID(ctx, user.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, "hash-2", found.Password)
+	})
+}
+
+func TestRepositoryMemberships(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		owner, org := registerRepositoryUser(t, repos)
+		other, _ := registerRepositoryUser(t, repos)
+		orgRecord, err := repos.Organisations.FindByOrgID(ctx, org.OrgID)
+		require.NoError(t, err)
+
+		shared, err := repos.Organisations.ShareOrganisation(ctx, owner.UserID, other.UserID)
+		require.NoError(t, err)
+		assert.False(t, shared)
+
+		existing, err := repos.Organisations.AddMember(ctx, models.Membership{OrganisationID: orgRecord.ID, UserID: other.ID, Role: models.RoleViewer})
+		require.NoError(t, err)
+		assert.Nil(t, existing)
+
+		existing, err = repos.Organisations.AddMember(ctx, models.Membership{OrganisationID: orgRecord.ID, UserID: other.ID, Role: models.RoleAdmin})
+		require.NoError(t, err)
+		require.NotNil(t, existing)
+		assert.Equal(t, models.RoleViewer, existing.Role)
+
+		shared, err = repos.Organisations.ShareOrganisation(ctx, owner.UserID, other.UserID)
+		require.NoError(t, err)
+		assert.True(t, shared)
+	})
+}
+
+func TestRepositorySingleUseTokens(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		user, _ := registerRepositoryUser(t, repos)
+
+		token := &models.RefreshToken{TokenHash: utils.GenerateUUID(), FamilyID: utils.GenerateUUID(), UserID: user.UserID, ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, repos.RefreshTokens.Create(ctx, token))
+		claimed, err := repos.RefreshTokens.MarkUsed(ctx, token.ID)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+		claimed, err = repos.RefreshTokens.MarkUsed(ctx, token.ID)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+
+		first := &models.EmailVerification{JTI: utils.GenerateUUID(), UserID: user.UserID, ExpiresAt: time.Now().Add(time.Hour)}
+		second := &models.EmailVerification{JTI: utils.GenerateUUID(), UserID: user.UserID, ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, repos.EmailVerifications.Create(ctx, first))
+		require.NoError(t, repos.EmailVerifications.Create(ctx, second))
+		assert.Equal(t, repository.ErrTokenUsed, repos.EmailVerifications.Redeem(ctx, first.JTI, user.UserID))
+		require.NoError(t, repos.EmailVerifications.Redeem(ctx, second.JTI, user.UserID))
+		assert.Equal(t, repository.ErrTokenUsed, repos.EmailVerifications.Redeem(ctx, second.JTI, user.UserID))
+
+		found, err := repos.Users.FindByID(ctx, user.UserID)
+		require.NoError(t, err)
+		assert.True(t, found.EmailVerified)
+
+		reset := &models.PasswordReset{TokenHash: utils.GenerateUUID(), UserID: user.UserID, ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, repos.PasswordResets.Create(ctx, reset))
+		valid, err := repos.PasswordResets.FindValid(ctx, reset.TokenHash)
+		require.NoError(t, err)
+		require.NoError(t, repos.PasswordResets.Redeem(ctx, valid, "hash-reset", 3))
+		assert.Equal(t, repository.ErrTokenUsed, repos.PasswordResets.Redeem(ctx, valid, "hash-again", 3))
+		_, err = repos.PasswordResets.FindValid(ctx, reset.TokenHash)
+		assert.Equal(t, repository.ErrNotFound, err)
+	})
+}
+
+func TestRepositoryTwoFactor(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		user, _ := registerRepositoryUser(t, repos)
+
+		require.NoError(t, repos.TwoFactor.SetSecret(ctx, user.UserID, "SECRET"))
+		require.NoError(t, repos.TwoFactor.Enable(ctx, user.UserID, 100, []string{"code-a", "code-b"}))
+
+		used, err := repos.TwoFactor.UseStep(ctx, user.UserID, 100)
+		require.NoError(t, err)
+		assert.False(t, used, "the step used to enable must not be accepted again")
+		used, err = repos.TwoFactor.UseStep(ctx, user.UserID, 101)
+		require.NoError(t, err)
+		assert.True(t, used)
+
+		used, err = repos.TwoFactor.UseRecoveryCode(ctx, user.UserID, "code-a")
+		require.NoError(t, err)
+		assert.True(t, used)
+		used, err = repos.TwoFactor.UseRecoveryCode(ctx, user.UserID, "code-a")
+		require.NoError(t, err)
+		assert.False(t, used)
+
+		require.NoError(t, repos.TwoFactor.Disable(ctx, user.UserID))
+		found, err := repos.Users.FindByID(ctx, user.UserID)
+		require.NoError(t, err)
+		assert.False(t, found.TOTPEnabled)
+		assert.Empty(t, found.TOTPSecret)
+		used, err = repos.TwoFactor.UseRecoveryCode(ctx, user.UserID, "code-b")
+		require.NoError(t, err)
+		assert.False(t, used)
+	})
+}
+
+func TestRepositoryAccessTokens(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repos *repository.Repositories) {
+		ctx := context.Background()
+		user, _ := registerRepositoryUser(t, repos)
+		other, _ := registerRepositoryUser(t, repos)
+
+		first := &models.PersonalAccessToken{TokenID: utils.GenerateUUID(), UserID: user.UserID, Name: "first", TokenHash: utils.GenerateUUID(), Scopes: "read"}
+		second := &models.PersonalAccessToken{TokenID: utils.GenerateUUID(), UserID: user.UserID, Name: "second", TokenHash: utils.GenerateUUID(), Scopes: "read write"}
+		require.NoError(t, repos.AccessTokens.Create(ctx, first))
+		require.NoError(t, repos.AccessTokens.Create(ctx, second))
+
+		usedAt := time.Now().Truncate(time.Second)
+		require.NoError(t, repos.AccessTokens.Touch(ctx, second.ID, usedAt))
+		found, err := repos.AccessTokens.FindByHash(ctx, second.TokenHash)
+		require.NoError(t, err)
+		require.NotNil(t, found.LastUsedAt)
+		assert.True(t, usedAt.Equal(*found.LastUsedAt))
+		assert.Equal(t, []string{"read", "write"}, found.ScopeList())
+
+		// Only the owner can revoke a token, and only once.
+		assert.Equal(t, repository.ErrNotFound, repos.AccessTokens.Revoke(ctx, other.UserID, first.TokenID))
+		require.NoError(t, repos.AccessTokens.Revoke(ctx, user.UserID, first.TokenID))
+		assert.Equal(t, repository.ErrNotFound, repos.AccessTokens.Revoke(ctx, user.UserID, first.TokenID))
+
+		tokens, err := repos.AccessTokens.ListForUser(ctx, user.UserID)
+		require.NoError(t, err)
+		require.Len(t, tokens, 1)
+		assert.Equal(t, second.TokenID, tokens[0].TokenID)
+
+		revoked, err := repos.AccessTokens.FindByHash(ctx, first.TokenHash)
+		require.NoError(t, err)
+		assert.NotNil(t, revoked.RevokedAt)
+
+		require.NoError(t, repos.AccessTokens.RevokeAllForUser(ctx, user.UserID))
+		tokens, err = repos.AccessTokens.ListForUser(ctx, user.UserID)
+		require.NoError(t, err)
+		assert.Empty(t, tokens)
+	})
+}
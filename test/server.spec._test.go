@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"hng/config"
+	"hng/server"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testServerConfig() config.ServerConfig {
+	cfg := config.Default().Server
+	cfg.Addr = "127.0.0.1:0"
+	return cfg
+}
+
+// startServer serves handler on a random port and returns its URL, a
+// function that triggers shutdown, and the result of Serve.
+func startServer(t *testing.T, cfg config.ServerConfig, handler http.Handler) (string, context.CancelFunc, <-chan error) {
+	ln, err := net.Listen("tcp", cfg.Addr)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, server.New(cfg, handler), ln, cfg) }()
+	return ln.Addr().String(), cancel, done
+}
+
+func TestServerAppliesLimits(t *testing.T) {
+	cfg := testServerConfig()
+	srv := server.New(cfg, http.NotFoundHandler())
+	assert.Equal(t, cfg.ReadTimeout.Duration, srv.ReadTimeout)
+	assert.Equal(t, cfg.ReadHeaderTimeout.Duration, srv.ReadHeaderTimeout)
+	assert.Equal(t, cfg.WriteTimeout.Duration, srv.WriteTimeout)
+	assert.Equal(t, cfg.IdleTimeout.Duration, srv.IdleTimeout)
+	assert.Equal(t, cfg.MaxHeaderBytes, srv.MaxHeaderBytes)
+}
+
+func TestServerDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	addr, shutdown, done := startServer(t, testServerConfig(), handler)
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-started
+	shutdown()
+
+	assert.Equal(t, http.StatusOK, <-status)
+	assert.NoError(t, <-done)
+
+	_, err := net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.ShutdownTimeout = config.Duration{Duration: 50 * time.Millisecond}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	addr, shutdown, done := startServer(t, cfg, handler)
+
+	go http.Get("http://" + addr)
+	<-started
+	shutdown()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not give up on a stuck request")
+	}
+}
+
+func writeSelfSignedCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestServerTLS(t *testing.T) {
+	cfg := testServerConfig()
+	cfg.TLSCertFile, cfg.TLSKeyFile = writeSelfSignedCert(t)
+
+	addr, shutdown, done := startServer(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotNil(t, r.TLS)
+	}))
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + addr)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	shutdown()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"hng/models"
+	"hng/utils"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createAccessToken creates a personal access token with scopes for the
+// user logged in with session and returns the creation response data.
+func createAccessToken(t *testing.T, router *gin.Engine, session string, scopes ...string) models.AccessTokenResponse {
+	w := postWithToken(router, "/api/users/me/tokens", session, map[string]interface{}{"name": "CI", "scopes": scopes})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var response struct {
+		Data models.AccessTokenResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Data
+}
+
+func listAccessTokens(t *testing.T, router *gin.Engine, session string) []models.AccessTokenResponse {
+	w := getWithToken(router, "/api/users/me/tokens", session)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data struct {
+			Tokens []models.AccessTokenResponse `json:"tokens"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Data.Tokens
+}
+
+func TestAccessTokenLifecycle(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	session := data["accessToken"].(string)
+
+	created := createAccessToken(t, router, session, "read", "write")
+	assert.True(t, strings.HasPrefix(created.Token, utils.AccessTokenPrefix))
+	assert.Equal(t, []string{"read", "write"}, created.Scopes)
+	assert.Nil(t, created.ExpiresAt)
+
+	// Only a hash is stored.
+	stored, err := testRepos.AccessTokens.FindByHash(context.Background(), utils.HashToken(created.Token))
+	require.NoError(t, err)
+	assert.NotContains(t, stored.TokenHash, created.Token)
+
+	// The token works like a session and its use is recorded.
+	w := getWithToken(router, "/api/organisations", created.Token)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = getWithToken(router, "/api/users/"+userIDOf(data), created.Token)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	tokens := listAccessTokens(t, router, session)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, created.TokenID, tokens[0].TokenID)
+	assert.Empty(t, tokens[0].Token, "the token is only shown when created")
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	w = requestRoute(router, "DELETE", "/api/users/me/tokens/"+created.TokenID, "Bearer "+session)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = getWithToken(router, "/api/organisations", created.Token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "AUTH_TOKEN_REVOKED", decodeError(t, w).Code)
+	assert.Empty(t, listAccessTokens(t, router, session))
+
+	w = requestRoute(router, "DELETE", "/api/users/me/tokens/"+created.TokenID, "Bearer "+session)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "ACCESS_TOKEN_NOT_FOUND", decodeError(t, w).Code)
+}
+
+func TestAccessTokenScopes(t *testing.T) {
+	router := setupRouter()
+	session := registerTestUser(t, router)["accessToken"].(string)
+	readOnly := createAccessToken(t, router, session, "read").Token
+	writeOnly := createAccessToken(t, router, session, "write").Token
+
+	w := getWithToken(router, "/api/organisations", readOnly)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postWithToken(router, "/api/organisations", readOnly, map[string]string{"name": "Scripted"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "AUTH_INSUFFICIENT_SCOPE", decodeError(t, w).Code)
+
+	w = getWithToken(router, "/api/organisations", writeOnly)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = postWithToken(router, "/api/organisations", writeOnly, map[string]string{"name": "Scripted"})
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+}
+
+func TestAccessTokensCannotManageCredentials(t *testing.T) {
+	router := setupRouter()
+	session := registerTestUser(t, router)["accessToken"].(string)
+	token := createAccessToken(t, router, session, "read", "write")
+
+	routes := []struct{ method, path string }{
+		{"GET", "/api/users/me/tokens"},
+		{"POST", "/api/users/me/tokens"},
+		{"DELETE", "/api/users/me/tokens/" + token.TokenID},
+		{"POST", "/api/users/me/password"},
+		{"POST", "/api/users/me/2fa/setup"},
+		{"POST", "/auth/logout"},
+		{"POST", "/auth/logout-all"},
+	}
+	for _, route := range routes {
+		w := requestRoute(router, route.method, route.path, "Bearer "+token.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code, route.method+" "+route.path)
+		assert.Equal(t, "AUTH_SESSION_REQUIRED", decodeError(t, w).Code)
+	}
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	session := data["accessToken"].(string)
+
+	w := postWithToken(router, "/api/users/me/tokens", session, map[string]interface{}{"name": "CI", "scopes": []string{"read"}, "expiresInDays": 30})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response struct {
+		Data models.AccessTokenResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Data.ExpiresAt)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), *response.Data.ExpiresAt, time.Minute)
+
+	token, err := utils.GenerateAccessToken()
+	require.NoError(t, err)
+	expiredAt := time.Now().Add(-time.Minute)
+	require.NoError(t, testRepos.AccessTokens.Create(context.Background(), &models.PersonalAccessToken{
+		TokenID:   utils.GenerateUUID(),
+		UserID:    userIDOf(data),
+		Name:      "Expired",
+		TokenHash: utils.HashToken(token),
+		Scopes:    "read",
+		ExpiresAt: &expiredAt,
+	}))
+	w = getWithToken(router, "/api/organisations", token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "AUTH_INVALID_TOKEN", decodeError(t, w).Code)
+
+	w = getWithToken(router, "/api/organisations", utils.AccessTokenPrefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "AUTH_INVALID_TOKEN", decodeError(t, w).Code)
+}
+
+func TestCreateAccessTokenValidation(t *testing.T) {
+	router := setupRouter()
+	session := registerTestUser(t, router)["accessToken"].(string)
+
+	tests := map[string]map[string]interface{}{
+		"missing name":   {"scopes": []string{"read"}},
+		"no scopes":      {"name": "CI", "scopes": []string{}},
+		"unknown scope":  {"name": "CI", "scopes": []string{"admin"}},
+		"expiry too far": {"name": "CI", "scopes": []string{"read"}, "expiresInDays": 366},
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := postWithToken(router, "/api/users/me/tokens", session, body)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+			assert.NotEmpty(t, decodeError(t, w).Errors)
+		})
+	}
+}
+
+func TestResetPasswordRevokesAccessTokens(t *testing.T) {
+	router := setupRouter()
+	data := registerTestUser(t, router)
+	email := data["user"].(map[string]interface{})["email"].(string)
+	token := createAccessToken(t, router, data["accessToken"].(string), "read").Token
+
+	w := postWithToken(router, "/auth/forgot-password", "", map[string]string{"email": email})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = postWithToken(router, "/auth/reset-password", "", map[string]string{"token": mailedToken(t, email), "password": "newpassword456"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = getWithToken(router, "/api/organisations", token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserSelf(t *testing.T) {
+	router := setupRouter()
+	user := registerTestUser(t, router)
+
+	w := getWithToken(router, "/api/users/"+userIDOf(user), user["accessToken"].(string))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, userIDOf(user), data["userId"])
+	assert.NotContains(t, data, "password")
+	assert.NotContains(t, w.Body.String(), "$2a$")
+}
+
+func TestGetUserSharingOrganisation(t *testing.T) {
+	router := setupRouter()
+	owner := registerTestUser(t, router)
+	member := registerTestUser(t, router)
+	orgID := createTestOrganisation(t, router, owner["accessToken"].(string))
+
+	// Before sharing an organisation the users cannot see each other
+	w := getWithToken(router, "/api/users/"+userIDOf(member), owner["accessToken"].(string))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = postWithToken(router, "/api/organisations/"+orgID+"/users", owner["accessToken"].(string),
+		map[string]string{"userId": userIDOf(member)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = getWithToken(router, "/api/users/"+userIDOf(member), owner["accessToken"].(string))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = getWithToken(router, "/api/users/"+userIDOf(owner), member["accessToken"].(string))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRegisterAndLoginDoNotExposePassword(t *testing.T) {
+	router := setupRouter()
+	user := registerTestUser(t, router)
+	assert.NotContains(t, user["user"], "password")
+
+	w := postWithToken(router, "/auth/login", "", map[string]string{
+		"email":    user["user"].(map[string]interface{})["email"].(string),
+		"password": "password123",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "password")
+}
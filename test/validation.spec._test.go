@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"hng/apperr"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func postRaw(router *gin.Engine, path, body, acceptLanguage string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestValidationMessagesUseJSONFieldNames(t *testing.T) {
+	router := setupRouter()
+
+	w := postRaw(router, "/auth/login", `{"email": "not-an-email"}`, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	response := decodeError(t, w)
+	assert.Equal(t, []apperr.FieldError{
+		{Field: "email", Message: "email must be a valid email address"},
+		{Field: "password", Message: "password is a required field"},
+	}, response.Errors)
+}
+
+func TestValidationMessagesFollowAcceptLanguage(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		acceptLanguage string
+		expected       string
+	}{
+		{"fr", "password est un champ obligatoire"},
+		{"fr-CA, en;q=0.8", "password est un champ obligatoire"},
+		{"de, en;q=0.5, fr;q=0.9", "password est un champ obligatoire"},
+		{"en-GB", "password is a required field"},
+		{"de", "password is a required field"},
+		{"", "password is a required field"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.acceptLanguage, func(t *testing.T) {
+			w := postRaw(router, "/auth/login", `{"email": "jane@example.com"}`, tc.acceptLanguage)
+			response := decodeError(t, w)
+			assert.Equal(t, []apperr.FieldError{{Field: "password", Message: tc.expected}}, response.Errors)
+		})
+	}
+}
+
+func TestMalformedRequestBodies(t *testing.T) {
+	router := setupRouter()
+
+	for _, body := range []string{"", "{", "not json", `{"email": "jane@example.com",}`} {
+		w := postRaw(router, "/auth/login", body, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		response := decodeError(t, w)
+		assert.Equal(t, string(apperr.MalformedRequest), response.Code, body)
+	}
+}
+
+func TestWrongJSONTypeIsReportedOnTheField(t *testing.T) {
+	router := setupRouter()
+
+	w := postRaw(router, "/auth/login", `{"email": "jane@example.com", "password": 123}`, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	response := decodeError(t, w)
+	assert.Equal(t, []apperr.FieldError{{Field: "password", Message: "password must be of type string"}}, response.Errors)
+
+	w = postRaw(router, "/auth/login", `{"email": "jane@example.com", "password": 123}`, "fr")
+	response = decodeError(t, w)
+	assert.Equal(t, []apperr.FieldError{{Field: "password", Message: "password doit être de type string"}}, response.Errors)
+}
+
+func TestPasswordPolicyMessagesFollowAcceptLanguage(t *testing.T) {
+	router := setupRouter()
+	body := `{"firstName": "Jeanne", "lastName": "Martin", "email": "jeanne@example.com", "password": "a"}`
+
+	w := postRaw(router, "/auth/register", body, "fr")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, []apperr.FieldError{
+		{Field: "password", Message: "Le mot de passe doit contenir au moins 8 caractères"},
+		{Field: "password", Message: "Le mot de passe doit contenir un chiffre"},
+	}, decodeError(t, w).Errors)
+
+	w = postRaw(router, "/auth/register", body, "")
+	assert.Equal(t, []apperr.FieldError{
+		{Field: "password", Message: "Password must be at least 8 characters long"},
+		{Field: "password", Message: "Password must contain a digit"},
+	}, decodeError(t, w).Errors)
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"hng/mailer"
+	"hng/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMailer records messages instead of sending them.
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// lastMessage returns the most recent message sent to address.
+func (m *testMailer) lastMessage(address string) (mailer.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == address {
+			return m.messages[i], true
+		}
+	}
+	return mailer.Message{}, false
+}
+
+var sentMail *testMailer
+
+// blockingMailer holds every send until release is closed, like a slow
+// SMTP server.
+type blockingMailer struct {
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	<-m.release
+	return nil
+}
+
+// assertAnswersWithoutMail checks that posting body to path responds while
+// the mailer is stuck, so slow mail cannot reveal that an account exists.
+func assertAnswersWithoutMail(t *testing.T, router *gin.Engine, path string, body interface{}) {
+	blocked := &blockingMailer{release: make(chan struct{})}
+	testHandler.Mailer = blocked
+	defer func() {
+		close(blocked.release)
+		testHandler.Wait()
+		testHandler.Mailer = sentMail
+	}()
+
+	done := make(chan int)
+	go func() { done <- postWithToken(router, path, "", body).Code }()
+	select {
+	case code := <-done:
+		assert.Equal(t, http.StatusOK, code)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s waited for the mail to be sent", path)
+	}
+}
+
+var tokenPattern = regexp.MustCompile(`token=([^\s]+)`)
+
+// mailedToken returns the token in the last mail sent to address, once
+// mail sent in the background has gone out.
+func mailedToken(t *testing.T, address string) string {
+	testHandler.Wait()
+	msg, ok := sentMail.lastMessage(address)
+	require.True(t, ok, "no mail sent to %s", address)
+	match := tokenPattern.FindStringSubmatch(msg.Body)
+	require.NotNil(t, match, "no token in mail to %s", address)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func verificationToken(t *testing.T, address string) string {
+	return mailedToken(t, address)
+}
+
+func verifyEmail(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	return postWithToken(router, "/auth/verify-email", "", map[string]string{"token": token})
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	router := setupRouter()
+	email := "user-" + utils.GenerateUUID() + "@example.com"
+	w := postWithToken(router, "/auth/register", "", map[string]string{
+		"firstName": "Test", "lastName": "User", "email": email, "password": "password123",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": "password123"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = verifyEmail(router, verificationToken(t, email))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postWithToken(router, "/auth/login", "", map[string]string{"email": email, "password": "password123"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	user := response["data"].(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, true, user["emailVerified"])
+}
+
+func TestVerificationTokenIsSingleUse(t *testing.T) {
+	router := setupRouter()
+	email := "user-" + utils.GenerateUUID() + "@example.com"
+	postWithToken(router, "/auth/register", "", map[string]string{
+		"firstName": "Test", "lastName": "User", "email": email, "password": "password123",
+	})
+	token := verificationToken(t, email)
+
+	assert.Equal(t, http.StatusOK, verifyEmail(router, token).Code)
+	assert.Equal(t, http.StatusBadRequest, verifyEmail(router, token).Code)
+}
+
+func TestVerificationTokenRejectsForgeriesAndExpiry(t *testing.T) {
+	router := setupRouter()
+
+	assert.Equal(t, http.StatusBadRequest, verifyEmail(router, "not-a-token").Code)
+
+	// An access token cannot be used as a verification token
+	accessToken, _ := utils.GenerateToken(utils.GenerateUUID(), "test@example.com")
+	assert.Equal(t, http.StatusBadRequest, verifyEmail(router, accessToken).Code)
+
+	expired, _, _ := utils.GenerateActionToken(utils.PurposeVerifyEmail, utils.GenerateUUID(), "test@example.com", -time.Minute)
+	assert.Equal(t, http.StatusBadRequest, verifyEmail(router, expired).Code)
+}
+
+func TestResendVerificationSupersedesOldToken(t *testing.T) {
+	router := setupRouter()
+	email := "user-" + utils.GenerateUUID() + "@example.com"
+	postWithToken(router, "/auth/register", "", map[string]string{
+		"firstName": "Test", "lastName": "User", "email": email, "password": "password123",
+	})
+	oldToken := verificationToken(t, email)
+
+	w := postWithToken(router, "/auth/resend-verification", "", map[string]string{"email": email})
+	assert.Equal(t, http.StatusOK, w.Code)
+	newToken := verificationToken(t, email)
+	assert.NotEqual(t, oldToken, newToken)
+
+	assert.Equal(t, http.StatusBadRequest, verifyEmail(router, oldToken).Code)
+	assert.Equal(t, http.StatusOK, verifyEmail(router, newToken).Code)
+
+	// Unknown addresses get the same answer
+	unknown := postWithToken(router, "/auth/resend-verification", "", map[string]string{"email": "nobody-" + utils.GenerateUUID() + "@example.com"})
+	assert.Equal(t, http.StatusOK, unknown.Code)
+	assert.Equal(t, w.Body.String(), unknown.Body.String())
+}
+
+func TestResendVerificationDoesNotWaitForMail(t *testing.T) {
+	router := setupRouter()
+	email := "user-" + utils.GenerateUUID() + "@example.com"
+	w := postWithToken(router, "/auth/register", "", map[string]string{
+		"firstName": "Test", "lastName": "User", "email": email, "password": "password123",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	assertAnswersWithoutMail(t, router, "/auth/resend-verification", map[string]string{"email": email})
+}
@@ -1,47 +1,245 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hng/config"
 	"time"
-	"golang.org/x/crypto/bcrypt"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-var jwtKey = []byte("your_secret_key")
+// keyProvider starts out with a random secret, so tokens signed before
+// SetKeyProvider is called do not survive a restart.
+var keyProvider KeyProvider = func() KeyProvider {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return &StaticKeyProvider{
+		keys:   map[string]*SigningKey{"default": NewHMACKey("default", secret)},
+		order:  []string{"default"},
+		active: "default",
+	}
+}()
+
+// SetKeyProvider replaces the keys tokens are signed and verified with.
+func SetKeyProvider(p KeyProvider) {
+	keyProvider = p
+}
+
+func Keys() KeyProvider {
+	return keyProvider
+}
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
 
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+	MFATokenTTL          = 5 * time.Minute
+)
+
+// Claims are carried by every token we sign. The user's UserID is the
+// standard sub claim. IssuedAtMicros repeats iat in microseconds, the
+// precision session revocations are stored with, so tokens issued just
+// after a revocation in the same second stay valid.
 type Claims struct {
-	UserID string `json:"userId"`
-	Email  string `json:"email"`
+	Email          string `json:"email"`
+	Purpose        string `json:"purpose,omitempty"`
+	IssuedAtMicros int64  `json:"iat_us,omitempty"`
 	jwt.StandardClaims
 }
 
+// issuedBefore reports whether the token was issued before t, to the
+// microsecond if it carries iat_us and to the second otherwise.
+func (c *Claims) issuedBefore(t time.Time) bool {
+	if c.IssuedAtMicros != 0 {
+		return c.IssuedAtMicros < t.UnixMicro()
+	}
+	return c.IssuedAt < t.Unix()
+}
+
+const (
+	PurposeVerifyEmail = "verify_email"
+	PurposeMFA         = "mfa_pending"
+)
+
+// tokenSettings are the issuer and audience stamped on every token and
+// required when one is validated, and how far the clocks of the issuing
+// and validating servers may drift apart.
+var tokenSettings = struct {
+	issuer    string
+	audience  string
+	clockSkew time.Duration
+}{"hng", "hng-api", 30 * time.Second}
 
-func GenerateToken(email string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		Email: email,
+// ConfigureTokens sets the issuer, audience and allowed clock skew of
+// tokens from cfg.
+func ConfigureTokens(cfg config.JWTConfig) {
+	tokenSettings.issuer = cfg.Issuer
+	tokenSettings.audience = cfg.Audience
+	tokenSettings.clockSkew = cfg.ClockSkew.Duration
+}
+
+func GenerateToken(userID, email string) (string, error) {
+	return signClaims(newClaims("", userID, email, AccessTokenTTL))
+}
+
+// audienceFor is the audience of tokens for purpose. Action tokens get
+// their own, so services that only check the signature, issuer and
+// audience of a token cannot mistake one for an access token.
+func audienceFor(purpose string) string {
+	if purpose == "" {
+		return tokenSettings.audience
+	}
+	return tokenSettings.audience + ":" + purpose
+}
+
+func newClaims(purpose, userID, email string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		Email:          email,
+		Purpose:        purpose,
+		IssuedAtMicros: now.UnixMicro(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			Subject:   userID,
+			Issuer:    tokenSettings.issuer,
+			Audience:  audienceFor(purpose),
+			Id:        GenerateUUID(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
 }
 
+func signClaims(claims *Claims) (string, error) {
+	key, err := keyProvider.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// ValidateToken verifies an access token. Tokens issued for another purpose,
+// such as email verification, are rejected.
 func ValidateToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, "")
+}
+
+// GenerateActionToken signs a single-purpose token for userID, such as an
+// email verification link. Callers persist the returned claims' Id to make
+// the token single-use.
+func GenerateActionToken(purpose, userID, email string, ttl time.Duration) (string, *Claims, error) {
+	claims := newClaims(purpose, userID, email, ttl)
+	signed, err := signClaims(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+func ValidateActionToken(tokenString, purpose string) (*Claims, error) {
+	return parseToken(tokenString, purpose)
+}
+
+// tokenParser checks only the signature; the time-based claims are checked
+// by validateClaims, which allows for clock skew.
+var tokenParser = &jwt.Parser{SkipClaimsValidation: true}
+
+func parseToken(tokenString, purpose string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	token, err := tokenParser.ParseWithClaims(tokenString, claims, verificationKey)
 
 	if err != nil || !token.Valid {
 		return nil, err
 	}
+	if err := validateClaims(claims, audienceFor(purpose), time.Now()); err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("unexpected token purpose %q", claims.Purpose)
+	}
 
 	return claims, nil
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
\ No newline at end of file
+// validateClaims rejects tokens from another issuer or for an audience
+// other than audience, and tokens that are expired or not yet valid at now
+// give or take the allowed clock skew.
+func validateClaims(claims *Claims, audience string, now time.Time) error {
+	skew := int64(tokenSettings.clockSkew / time.Second)
+	switch {
+	case claims.Subject == "":
+		return errors.New("token has no subject")
+	case claims.Id == "":
+		return errors.New("token has no id")
+	case claims.Issuer != tokenSettings.issuer:
+		return fmt.Errorf("unexpected token issuer %q", claims.Issuer)
+	case claims.Audience != audience:
+		return fmt.Errorf("unexpected token audience %q", claims.Audience)
+	case claims.ExpiresAt == 0 || now.Unix() > claims.ExpiresAt+skew:
+		return errors.New("token is expired")
+	case now.Unix() < claims.NotBefore-skew:
+		return errors.New("token is not valid yet")
+	case now.Unix() < claims.IssuedAt-skew:
+		return errors.New("token was issued in the future")
+	}
+	return nil
+}
+
+// verificationKey resolves the key named by the token's kid header and
+// rejects tokens whose algorithm does not match that key.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := keyProvider.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// GenerateRefreshToken returns an opaque random token. Only its hash is
+// persisted, see HashToken.
+func GenerateRefreshToken() (string, error) {
+	return GenerateOpaqueToken()
+}
+
+// GenerateOpaqueToken returns 32 random bytes, base64url encoded, for tokens
+// that are looked up by hash rather than verified by signature.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AccessTokenPrefix starts every personal access token, so the auth
+// middleware can tell them from JWTs and secret scanners can spot them.
+const AccessTokenPrefix = "hng_pat_"
+
+// GenerateAccessToken returns a new personal access token.
+func GenerateAccessToken() (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return AccessTokenPrefix + token, nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
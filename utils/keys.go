@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hng/config"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SigningKey is a key used to sign or verify tokens. Private is nil for keys
+// that are only kept around to verify tokens issued before a rotation.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+// KeyProvider supplies the key new tokens are signed with and every key that
+// tokens may still be verified with.
+type KeyProvider interface {
+	SigningKey() (*SigningKey, error)
+	VerificationKey(kid string) (*SigningKey, error)
+	PublicKeys() []*SigningKey
+}
+
+// StaticKeyProvider serves a fixed set of keys, one of which is active.
+type StaticKeyProvider struct {
+	keys   map[string]*SigningKey
+	order  []string
+	active string
+}
+
+func NewStaticKeyProvider(active string, keys ...*SigningKey) (*StaticKeyProvider, error) {
+	p := &StaticKeyProvider{keys: map[string]*SigningKey{}, active: active}
+	for _, key := range keys {
+		if _, ok := p.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		p.keys[key.ID] = key
+		p.order = append(p.order, key.ID)
+	}
+
+	key, ok := p.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", active)
+	}
+	if key.Private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", active)
+	}
+	return p, nil
+}
+
+func (p *StaticKeyProvider) SigningKey() (*SigningKey, error) {
+	return p.keys[p.active], nil
+}
+
+func (p *StaticKeyProvider) VerificationKey(kid string) (*SigningKey, error) {
+	if kid == "" {
+		kid = p.active
+	}
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// PublicKeys returns the asymmetric keys in load order. Shared secrets are
+// never published.
+func (p *StaticKeyProvider) PublicKeys() []*SigningKey {
+	var keys []*SigningKey
+	for _, kid := range p.order {
+		if _, ok := p.keys[kid].Method.(*jwt.SigningMethodHMAC); ok {
+			continue
+		}
+		keys = append(keys, p.keys[kid])
+	}
+	return keys
+}
+
+// NewHMACKey wraps a shared secret as an HS256 signing key.
+func NewHMACKey(kid string, secret []byte) *SigningKey {
+	return &SigningKey{ID: kid, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+}
+
+// LoadKeyProvider reads RSA or ECDSA keys from PEM files. Each key is
+// identified by its file name without extension. Files holding only a public
+// key can verify tokens but cannot be the active key.
+func LoadKeyProvider(paths []string, active string) (*StaticKeyProvider, error) {
+	var keys []*SigningKey
+	for _, path := range paths {
+		key, err := loadPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+	if active == "" {
+		active = keys[0].ID
+	}
+	return NewStaticKeyProvider(active, keys...)
+}
+
+// LoadKeyProviderFromConfig loads the PEM keys in cfg.KeyFiles, with
+// cfg.ActiveKID selecting the signing key. Without key files tokens are
+// signed with HS256 using cfg.Secret.
+func LoadKeyProviderFromConfig(cfg config.JWTConfig) (*StaticKeyProvider, error) {
+	if len(cfg.KeyFiles) == 0 {
+		return NewStaticKeyProvider("default", NewHMACKey("default", []byte(cfg.Secret)))
+	}
+	return LoadKeyProvider(cfg.KeyFiles, cfg.ActiveKID)
+}
+
+func loadPEMKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key, err := newAsymmetricKey(kid, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func newAsymmetricKey(kid string, parsed interface{}) (*SigningKey, error) {
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case *ecdsa.PrivateKey:
+		method, err := ecdsaMethod(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: kid, Method: method, Private: k, Public: &k.PublicKey}, nil
+	case *ecdsa.PublicKey:
+		method, err := ecdsaMethod(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: kid, Method: method, Public: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+func ecdsaMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", curve.Params().Name)
+	}
+}
+
+// JWK is the JSON Web Key representation of a public key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS returns the public half of every asymmetric key in provider.
+func JWKS(provider KeyProvider) []JWK {
+	keys := []JWK{}
+	for _, key := range provider.PublicKeys() {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"bufio"
+	"hng/config"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	ut "github.com/go-playground/universal-translator"
+)
+
+// PasswordPolicy describes what a new password must look like.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength is in bytes, since that is what bcrypt limits. Zero means
+	// no limit.
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Blocklist holds lower-cased common or breached passwords.
+	Blocklist map[string]struct{}
+	// HistorySize is how many previous passwords may not be reused.
+	HistorySize int
+}
+
+// Rules a password can break. Each is also the key of its message in the
+// validation translations.
+const (
+	RulePasswordMinLength = "password_min_length"
+	RulePasswordMaxLength = "password_max_length"
+	RulePasswordUpper     = "password_upper"
+	RulePasswordLower     = "password_lower"
+	RulePasswordDigit     = "password_digit"
+	RulePasswordSymbol    = "password_symbol"
+	RulePasswordCommon    = "password_common"
+	RulePasswordReused    = "password_reused"
+)
+
+// PolicyViolation is one broken rule. Param fills in the {0} of the rule's
+// message, where it has one.
+type PolicyViolation struct {
+	Rule  string
+	Param string
+}
+
+// PasswordPolicyError lists every rule a password broke.
+type PasswordPolicyError struct {
+	Field      string
+	Violations []PolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	trans, _ := translators.GetTranslator("en")
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message(trans)
+	}
+	return "password policy violated: " + strings.Join(messages, "; ")
+}
+
+// Message describes the violation in trans's language.
+func (v PolicyViolation) Message(trans ut.Translator) string {
+	message, err := trans.T(v.Rule, v.Param)
+	if err != nil {
+		return v.Rule
+	}
+	return message
+}
+
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:    8,
+		MaxLength:    256,
+		RequireLower: true,
+		RequireDigit: true,
+		Blocklist:    map[string]struct{}{},
+		HistorySize:  3,
+	}
+}
+
+var passwordPolicy = DefaultPasswordPolicy()
+
+func SetPasswordPolicy(p *PasswordPolicy) {
+	passwordPolicy = p
+}
+
+func CurrentPasswordPolicy() *PasswordPolicy {
+	return passwordPolicy
+}
+
+// NewPasswordPolicy builds a policy from cfg, loading the blocklist file
+// if one is set.
+func NewPasswordPolicy(cfg config.PasswordConfig) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{
+		MinLength:     cfg.MinLength,
+		MaxLength:     cfg.MaxPasswordLength(),
+		RequireUpper:  cfg.RequireUpper,
+		RequireLower:  cfg.RequireLower,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+		Blocklist:     map[string]struct{}{},
+		HistorySize:   cfg.HistorySize,
+	}
+
+	if cfg.BlocklistFile != "" {
+		blocklist, err := LoadPasswordBlocklist(cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		p.Blocklist = blocklist
+	}
+
+	return p, nil
+}
+
+// LoadPasswordBlocklist reads one password per line, ignoring blank lines
+// and lines starting with #.
+func LoadPasswordBlocklist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	blocklist := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return blocklist, scanner.Err()
+}
+
+// Validate checks password against the policy's composition rules and
+// blocklist. It returns a *PasswordPolicyError naming every broken rule.
+func (p *PasswordPolicy) Validate(field, password string) error {
+	var violations []PolicyViolation
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, PolicyViolation{Rule: RulePasswordMinLength, Param: strconv.Itoa(p.MinLength)})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, PolicyViolation{Rule: RulePasswordMaxLength, Param: strconv.Itoa(p.MaxLength)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, PolicyViolation{Rule: RulePasswordUpper})
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, PolicyViolation{Rule: RulePasswordLower})
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, PolicyViolation{Rule: RulePasswordDigit})
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, PolicyViolation{Rule: RulePasswordSymbol})
+	}
+
+	if _, ok := p.Blocklist[strings.ToLower(password)]; ok {
+		violations = append(violations, PolicyViolation{Rule: RulePasswordCommon})
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Field: field, Violations: violations}
+	}
+	return nil
+}
+
+// CheckReuse rejects password if matches reports it produced any of the
+// given previous hashes, newest first. Only the first HistorySize hashes are
+// considered.
+func (p *PasswordPolicy) CheckReuse(field, password string, previous []string, matches func(password, hash string) bool) error {
+	for i, hash := range previous {
+		if i >= p.HistorySize {
+			break
+		}
+		if matches(password, hash) {
+			return &PasswordPolicyError{
+				Field:      field,
+				Violations: []PolicyViolation{{Rule: RulePasswordReused, Param: strconv.Itoa(p.HistorySize)}},
+			}
+		}
+	}
+	return nil
+}
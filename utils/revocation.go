@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"hng/repository"
+	"sync"
+	"time"
+)
+
+// RevocationStore records revoked access tokens in a repository and keeps an
+// in-memory copy so the auth middleware does not query the database on every
+// request. The copy is reloaded every refreshInterval to pick up revocations
+// made by other instances.
+type RevocationStore struct {
+	repo            repository.RevocationRepository
+	refreshInterval time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	users    map[string]time.Time
+	loadedAt time.Time
+}
+
+func NewRevocationStore(repo repository.RevocationRepository) *RevocationStore {
+	return &RevocationStore{
+		repo:            repo,
+		refreshInterval: 30 * time.Second,
+		tokens:          map[string]time.Time{},
+		users:           map[string]time.Time{},
+	}
+}
+
+// RevokeToken revokes a single access token until expiresAt.
+func (s *RevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	if err := s.repo.RevokeToken(context.Background(), jti, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeUser revokes every access token issued to userID before the given
+// time.
+func (s *RevocationStore) RevokeUser(userID string, before time.Time) error {
+	if err := s.repo.RevokeUser(context.Background(), userID, before); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[userID] = before
+	s.mu.Unlock()
+	return nil
+}
+
+// IsRevoked reports whether the token described by claims has been revoked.
+func (s *RevocationStore) IsRevoked(claims *Claims) (bool, error) {
+	if err := s.refresh(); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[claims.Id]; ok {
+		return true, nil
+	}
+	if before, ok := s.users[claims.Subject]; ok && claims.issuedBefore(before) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *RevocationStore) refresh() error {
+	s.mu.RLock()
+	fresh := time.Since(s.loadedAt) < s.refreshInterval
+	s.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.loadedAt) < s.refreshInterval {
+		return nil
+	}
+
+	now := time.Now()
+	tokens, users, err := s.repo.Load(context.Background(), now)
+	if err != nil {
+		return err
+	}
+	s.tokens, s.users = tokens, users
+	s.loadedAt = now
+	return nil
+}
@@ -1,22 +1,168 @@
 package utils
 
 import (
+	"encoding/json"
+	"hng/apperr"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
 )
 
-type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
+// translators holds a message bundle per supported language. English is
+// the fallback for anything a client asks for that we do not have.
+var translators = ut.New(en.New(), en.New(), fr.New())
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Report fields by the names clients send rather than the Go names.
+	v.RegisterTagNameFunc(jsonFieldName)
+
+	registerTranslations(v, "en", en_translations.RegisterDefaultTranslations, enMessages)
+	registerTranslations(v, "fr", fr_translations.RegisterDefaultTranslations, frMessages)
 }
 
-func ValidationErrors(err error) []ValidationError {
-	var errs []ValidationError
-	for _, err := range err.(validator.ValidationErrors) {
-		e := ValidationError{
-			Field:   err.Field(),
-			Message: err.Error(),
+// Our own messages, on top of the validator's defaults: type mismatches in
+// JSON bodies and the password policy rules.
+var enMessages = map[string]string{
+	"type_mismatch":       "{0} must be of type {1}",
+	RulePasswordMinLength: "Password must be at least {0} characters long",
+	RulePasswordMaxLength: "Password must be at most {0} bytes long",
+	RulePasswordUpper:     "Password must contain an uppercase letter",
+	RulePasswordLower:     "Password must contain a lowercase letter",
+	RulePasswordDigit:     "Password must contain a digit",
+	RulePasswordSymbol:    "Password must contain a symbol",
+	RulePasswordCommon:    "Password is too common",
+	RulePasswordReused:    "Password must differ from your last {0} passwords",
+}
+
+var frMessages = map[string]string{
+	"type_mismatch":       "{0} doit être de type {1}",
+	RulePasswordMinLength: "Le mot de passe doit contenir au moins {0} caractères",
+	RulePasswordMaxLength: "Le mot de passe ne doit pas dépasser {0} octets",
+	RulePasswordUpper:     "Le mot de passe doit contenir une lettre majuscule",
+	RulePasswordLower:     "Le mot de passe doit contenir une lettre minuscule",
+	RulePasswordDigit:     "Le mot de passe doit contenir un chiffre",
+	RulePasswordSymbol:    "Le mot de passe doit contenir un symbole",
+	RulePasswordCommon:    "Le mot de passe est trop courant",
+	RulePasswordReused:    "Le mot de passe doit être différent de vos {0} derniers mots de passe",
+}
+
+func registerTranslations(v *validator.Validate, locale string, defaults func(*validator.Validate, ut.Translator) error, messages map[string]string) {
+	trans, _ := translators.GetTranslator(locale)
+	if err := defaults(v, trans); err != nil {
+		panic(err)
+	}
+	for key, message := range messages {
+		if err := trans.Add(key, message, false); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// Translator picks the message bundle that best matches an Accept-Language
+// header, falling back to English.
+func Translator(acceptLanguage string) ut.Translator {
+	trans, _ := translators.FindTranslator(preferredLocales(acceptLanguage)...)
+	return trans
+}
+
+// preferredLocales lists the languages in an Accept-Language header, most
+// preferred first. A regional tag such as fr-CA is followed by its base
+// language so it still matches the fr bundle.
+func preferredLocales(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			q = parsed
+		}
+		tags = append(tags, weighted{strings.ToLower(tag), q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var locales []string
+	for _, t := range tags {
+		locale := strings.ReplaceAll(t.tag, "-", "_")
+		locales = append(locales, locale)
+		if base, _, ok := strings.Cut(locale, "_"); ok {
+			locales = append(locales, base)
+		}
+	}
+	return locales
+}
+
+// ValidationErrors lists the fields err rejected, with messages from trans.
+// It returns nil if err is not about particular fields, such as a body that
+// is not JSON at all.
+func ValidationErrors(err error, trans ut.Translator) []apperr.FieldError {
+	var errs []apperr.FieldError
+	switch err := err.(type) {
+	case *PasswordPolicyError:
+		for _, v := range err.Violations {
+			errs = append(errs, apperr.FieldError{Field: err.Field, Message: v.Message(trans)})
+		}
+	case validator.ValidationErrors:
+		for _, fe := range err {
+			errs = append(errs, apperr.FieldError{Field: fe.Field(), Message: fe.Translate(trans)})
+		}
+	case *json.UnmarshalTypeError:
+		if err.Field != "" {
+			message, _ := trans.T("type_mismatch", err.Field, jsonType(err.Type))
+			errs = append(errs, apperr.FieldError{Field: err.Field, Message: message})
 		}
-		errs = append(errs, e)
 	}
 	return errs
 }
+
+// jsonType names the JSON value a Go type is decoded from.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}